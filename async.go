@@ -0,0 +1,95 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// asyncGatherer gathers the metrics of the wrapped gatherer in the background
+// and returns the last snapshot when gathered itself.
+type asyncGatherer struct {
+	g prometheus.Gatherer
+	// timestamps attaches the collection time to the metrics of a snapshot.
+	timestamps bool
+
+	mu  sync.RWMutex
+	mfs []*dto.MetricFamily
+	err error
+	// ready is closed once the first snapshot is taken.
+	ready chan struct{}
+}
+
+func newAsyncGatherer() *asyncGatherer {
+	return &asyncGatherer{
+		ready: make(chan struct{}),
+	}
+}
+
+// Gather implements the prometheus.Gatherer interface. It waits for the first
+// snapshot, if there is none yet.
+func (a *asyncGatherer) Gather() ([]*dto.MetricFamily, error) {
+	<-a.ready
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.mfs, a.err
+}
+
+// run gathers a snapshot at the given interval until done is closed.
+func (a *asyncGatherer) run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	first := true
+	for {
+		begin := time.Now()
+		mfs, err := a.g.Gather()
+		log.Debugf("Background collection finished after %fs", time.Since(begin).Seconds())
+		if a.timestamps {
+			setTimestamps(mfs, begin)
+		}
+
+		a.mu.Lock()
+		a.mfs, a.err = mfs, err
+		a.mu.Unlock()
+		if first {
+			close(a.ready)
+			first = false
+		}
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// setTimestamps sets the timestamp of all metrics without one to the given
+// time.
+func setTimestamps(mfs []*dto.MetricFamily, t time.Time) {
+	ts := t.UnixNano() / int64(time.Millisecond)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if m.TimestampMs == nil {
+				m.TimestampMs = proto.Int64(ts)
+			}
+		}
+	}
+}
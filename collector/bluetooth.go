@@ -0,0 +1,120 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	bluetoothSubsystem = "bluetooth"
+	bluetoothDevice    = "hci0"
+)
+
+// RX bytes:1533 acl:0 sco:0 events:90 errors:0
+// TX bytes:2304 acl:0 sco:0 commands:90 errors:0
+var (
+	hciconfigRxBytes = regexp.MustCompile(`RX bytes:(\d+)`)
+	hciconfigTxBytes = regexp.MustCompile(`TX bytes:(\d+)`)
+)
+
+type bluetoothCollector struct {
+	present *prometheus.Desc
+	rxBytes *prometheus.Desc
+	txBytes *prometheus.Desc
+}
+
+func init() {
+	registerCollector("bluetooth", defaultDisabled, NewBluetoothCollector)
+}
+
+// NewBluetoothCollector returns a new Collector exposing the presence and
+// traffic of the bluetooth controller.
+func NewBluetoothCollector() (Collector, error) {
+	bc := &bluetoothCollector{
+		present: prometheus.NewDesc(
+			fqName("bluetooth", bluetoothSubsystem, "present"),
+			"Whether a bluetooth controller is present.",
+			nil, nil,
+		),
+		rxBytes: prometheus.NewDesc(
+			fqName("bluetooth", bluetoothSubsystem, "rx_bytes_total"),
+			"Total bytes received by the bluetooth controller.",
+			nil, nil,
+		),
+		txBytes: prometheus.NewDesc(
+			fqName("bluetooth", bluetoothSubsystem, "tx_bytes_total"),
+			"Total bytes sent by the bluetooth controller.",
+			nil, nil,
+		),
+	}
+	return bc, nil
+}
+
+// Update implements the Collector interface.
+func (c *bluetoothCollector) Update(ch chan<- prometheus.Metric) error {
+	// Pi models without bluetooth don't have the controller in sysfs.
+	_, err := os.Stat(sysFilePath("class/bluetooth/" + bluetoothDevice))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	present := err == nil
+	ch <- prometheus.MustNewConstMetric(
+		c.present,
+		prometheus.GaugeValue, boolToFloat(present),
+	)
+	if !present {
+		return nil
+	}
+
+	// sysfs doesn't expose traffic counters, so they are read from hciconfig,
+	// if it is installed.
+	hciconfig, err := exec.LookPath("hciconfig")
+	if err != nil {
+		log.Debugln("hciconfig not found, skipping bluetooth traffic")
+		return nil
+	}
+	stdout, err := exec.Command(hciconfig, bluetoothDevice).Output()
+	if err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		desc *prometheus.Desc
+		re   *regexp.Regexp
+	}{
+		{c.rxBytes, hciconfigRxBytes},
+		{c.txBytes, hciconfigTxBytes},
+	} {
+		match := m.re.FindSubmatch(stdout)
+		if match == nil {
+			continue
+		}
+		v, err := strconv.ParseFloat(string(match[1]), 64)
+		if err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			m.desc,
+			prometheus.CounterValue, v,
+		)
+	}
+
+	return nil
+}
@@ -14,8 +14,12 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,7 +30,10 @@ import (
 // Namespace defines the common namespace to be used by all metrics.
 const namespace = "rpi"
 
-const defaultEnabled = true
+const (
+	defaultEnabled  = true
+	defaultDisabled = false
+)
 
 var (
 	scrapeDurationDesc = prometheus.NewDesc(
@@ -41,6 +48,49 @@ var (
 		[]string{"collector"},
 		nil,
 	)
+	scrapeLastErrorDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_last_error_timestamp_seconds"),
+		"rpi_exporter: Unix time of the last failure of a collector, 0 if it hasn't failed since the exporter started.",
+		[]string{"collector"},
+		nil,
+	)
+	scrapeInFlightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collectors_in_flight"),
+		"rpi_exporter: Maximum number of collectors running concurrently during the scrape.",
+		nil,
+		nil,
+	)
+	scrapeGoroutineWaitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "goroutine_wait_seconds"),
+		"rpi_exporter: Average delay between launching the goroutine of a collector and the start of its update during the scrape.",
+		nil,
+		nil,
+	)
+	scrapeEnabledDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_enabled"),
+		"rpi_exporter: Whether a collector is enabled.",
+		[]string{"collector"},
+		nil,
+	)
+	registeredCollectorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "registered_collectors"),
+		"rpi_exporter: Number of collectors built into the exporter.",
+		nil,
+		nil,
+	)
+	collectorInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "collector_info"),
+		"rpi_exporter: A collector built into the exporter.",
+		[]string{"collector"},
+		nil,
+	)
+)
+
+var (
+	maxConcurrency = kingpin.Flag("collector.max-concurrency", "Maximum number of concurrent reads within a single collector.").Default("4").Int()
+	scrapeJitter   = kingpin.Flag("collector.scrape-jitter", "Maximum random delay before running the collectors, to spread the load of simultaneous scrapes. Must be lower than the scrape timeout.").Default("0s").Duration()
+	metricPrefixes = kingpin.Flag("collector.metric-prefix", "Prefix to add to the names of the metrics of a collector, as collector=prefix, e.g. gpu=hardware_. Can be repeated.").StringMap()
+	metricSuffixes = kingpin.Flag("collector.metric-suffix", "Suffix to add to the names of the metrics of a collector, as collector=suffix. Can be repeated.").StringMap()
 )
 
 var (
@@ -48,6 +98,17 @@ var (
 	collectorState = make(map[string]*bool)
 )
 
+// jitterRand provides the scrape jitter. It is seeded at startup so exporters
+// started with the same configuration don't sleep for the same durations.
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// collectorsInFlight is the number of collectors currently running, across
+// all concurrent scrapes. Must be accessed atomically.
+var collectorsInFlight int64
+
 // registerCollector registers a givec RPiCollector on the
 func registerCollector(collector string, isDefaultEnabled bool, factory func() (Collector, error)) {
 	// Get the default state as a string for the help flag.
@@ -58,9 +119,10 @@ func registerCollector(collector string, isDefaultEnabled bool, factory func() (
 		helpDefaultState = "disabled"
 	}
 
-	// Create the flags for the givec RPiCollector.
+	// Create the flags for the givec RPiCollector. Kingpin also accepts the
+	// negated --no-collector.<name> form for boolean flags.
 	flagName := fmt.Sprintf("collector.%s", collector)
-	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s).", collector, helpDefaultState)
+	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s). Use --no-%s to disable it.", collector, helpDefaultState, flagName)
 	defaultValue := fmt.Sprintf("%v", isDefaultEnabled)
 
 	flag := kingpin.Flag(flagName, flagHelp).Default(defaultValue).Bool()
@@ -69,15 +131,80 @@ func registerCollector(collector string, isDefaultEnabled bool, factory func() (
 	factories[collector] = factory
 }
 
+// fqName returns the fully-qualified name of a metric of the given collector,
+// with the prefix and suffix configured for the collector.
+func fqName(collector, subsystem, name string) string {
+	return metricName(collector, prometheus.BuildFQName(namespace, subsystem, name))
+}
+
+// metricName adds the prefix and suffix configured for the given collector to
+// the given metric name.
+func metricName(collector, name string) string {
+	return (*metricPrefixes)[collector] + name + (*metricSuffixes)[collector]
+}
+
+// Enabled reports whether the collector with the given name exists and is
+// enabled.
+func Enabled(collector string) bool {
+	enabled, exist := collectorState[collector]
+	return exist && *enabled
+}
+
+// EnabledCollectors returns the sorted names of all enabled collectors.
+func EnabledCollectors() []string {
+	var names []string
+	for name, enabled := range collectorState {
+		if *enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Collector is the interface a collector has to implement.
 type Collector interface {
-	// Get new metrics and expose them via prometheus registry.
+	// Get new metrics and expose them via prometheus registry. A
+	// non-fatal *CollectorError signals that the collector is unavailable.
 	Update(ch chan<- prometheus.Metric) error
 }
 
+// CollectorError is an error of a collector. Errors which aren't fatal signal
+// that the collector is unavailable, e.g. because the hardware lacks a
+// feature, and are skipped silently. Other errors returned by Update are
+// considered fatal.
+type CollectorError struct {
+	// Name of the collector, set when the error is handled.
+	Name  string
+	Err   error
+	Fatal bool
+}
+
+func (e *CollectorError) Error() string {
+	if e.Name == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s collector: %s", e.Name, e.Err)
+}
+
+func (e *CollectorError) Unwrap() error {
+	return e.Err
+}
+
+// errUnavailable returns a non-fatal CollectorError for the given error.
+func errUnavailable(err error) error {
+	return &CollectorError{Err: err, Fatal: false}
+}
+
 // RPiCollector implements the prometheus.Collector interface.
 type RPiCollector struct {
 	collectors map[string]Collector
+	// enabled holds the state of all registered collectors.
+	enabled map[string]bool
+
+	// lastErrors holds the time of the last failure of each collector.
+	lastErrorsMu *sync.Mutex
+	lastErrors   map[string]time.Time
 }
 
 // New creates a new Raspberry Pi collector.
@@ -97,10 +224,18 @@ func New(filters ...string) (*RPiCollector, error) {
 
 	// Get the requested collectors.
 	collectors := make(map[string]Collector)
+	state := make(map[string]bool)
 	for key, enabled := range collectorState {
+		state[key] = *enabled
 		if *enabled {
 			collector, err := factories[key]()
-			if err != nil {
+			// Skip collectors unsupported by the hardware.
+			var collErr *CollectorError
+			if errors.As(err, &collErr) && !collErr.Fatal {
+				log.Infof("%s collector disabled: %s", key, collErr.Err)
+				state[key] = false
+				continue
+			} else if err != nil {
 				return nil, err
 			}
 			if len(f) == 0 || f[key] {
@@ -108,35 +243,119 @@ func New(filters ...string) (*RPiCollector, error) {
 			}
 		}
 	}
-	return &RPiCollector{collectors}, nil
+	return &RPiCollector{
+		collectors:   collectors,
+		enabled:      state,
+		lastErrorsMu: &sync.Mutex{},
+		lastErrors:   make(map[string]time.Time),
+	}, nil
 }
 
 // Describe implements the prometheus.Collector interface.
 func (c RPiCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- scrapeLastErrorDesc
+	ch <- scrapeEnabledDesc
+	ch <- scrapeInFlightDesc
+	ch <- scrapeGoroutineWaitDesc
+	ch <- registeredCollectorsDesc
+	ch <- collectorInfoDesc
+	vcgencmdErrors.Describe(ch)
+	if *vcgencmdHistogram {
+		vcgencmdDuration.Describe(ch)
+	}
 }
 
 // Collect implements the prometheus.Collector interface.
 func (c RPiCollector) Collect(ch chan<- prometheus.Metric) {
+	// Delay the collection by a random fraction of the jitter.
+	if *scrapeJitter > 0 {
+		jitterMu.Lock()
+		delay := time.Duration(jitterRand.Int63n(int64(*scrapeJitter)))
+		jitterMu.Unlock()
+		time.Sleep(delay)
+	}
+
+	var (
+		peak   int64
+		waitMu sync.Mutex
+		wait   time.Duration
+	)
 	wg := sync.WaitGroup{}
 	wg.Add(len(c.collectors))
-	for name, c := range c.collectors {
-		go func(name string, c Collector) {
-			execute(name, c, ch)
+	for name, coll := range c.collectors {
+		launched := time.Now()
+		go func(name string, coll Collector) {
+			// Measure how long the goroutine waited to be scheduled.
+			waited := time.Since(launched)
+			waitMu.Lock()
+			wait += waited
+			waitMu.Unlock()
+
+			// Track the highest number of collectors running at once.
+			n := atomic.AddInt64(&collectorsInFlight, 1)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+					break
+				}
+			}
+			if err := execute(name, coll, ch); err != nil {
+				c.lastErrorsMu.Lock()
+				c.lastErrors[name] = time.Now()
+				c.lastErrorsMu.Unlock()
+			}
+			atomic.AddInt64(&collectorsInFlight, -1)
 			wg.Done()
-		}(name, c)
+		}(name, coll)
 	}
 	wg.Wait()
+	c.lastErrorsMu.Lock()
+	for name := range c.collectors {
+		var ts float64
+		if t, ok := c.lastErrors[name]; ok {
+			ts = float64(t.UnixNano()) / 1e9
+		}
+		ch <- prometheus.MustNewConstMetric(scrapeLastErrorDesc, prometheus.GaugeValue, ts, name)
+	}
+	c.lastErrorsMu.Unlock()
+	ch <- prometheus.MustNewConstMetric(scrapeInFlightDesc, prometheus.GaugeValue, float64(peak))
+	if len(c.collectors) > 0 {
+		ch <- prometheus.MustNewConstMetric(scrapeGoroutineWaitDesc, prometheus.GaugeValue, wait.Seconds()/float64(len(c.collectors)))
+	}
+	for name, enabled := range c.enabled {
+		ch <- prometheus.MustNewConstMetric(scrapeEnabledDesc, prometheus.GaugeValue, boolToFloat(enabled), name)
+	}
+	ch <- prometheus.MustNewConstMetric(registeredCollectorsDesc, prometheus.GaugeValue, float64(len(factories)))
+	for name := range factories {
+		ch <- prometheus.MustNewConstMetric(collectorInfoDesc, prometheus.GaugeValue, 1, name)
+	}
+	vcgencmdErrors.Collect(ch)
+	if *vcgencmdHistogram {
+		vcgencmdDuration.Collect(ch)
+	}
 }
 
-func execute(name string, c Collector, ch chan<- prometheus.Metric) {
+// execute updates the given collector and exports its scrape metrics. It
+// returns the error of the collector if it failed.
+func execute(name string, c Collector, ch chan<- prometheus.Metric) error {
 	// Update the collector and meassure its execution time.
 	begin := time.Now()
 	err := c.Update(ch)
 	duration := time.Since(begin)
 	var success float64
 
+	// Skip collectors unavailable on this system.
+	var collErr *CollectorError
+	if errors.As(err, &collErr) {
+		collErr.Name = name
+		if !collErr.Fatal {
+			log.Debugf("%s collector unavailable: %s", name, collErr.Err)
+			err = nil
+		}
+	}
+
 	// Log the execution status and set the appropriate success value.
 	if err != nil {
 		log.Errorf("%s collector failed after %fs: %s", name, duration.Seconds(), err)
@@ -149,4 +368,5 @@ func execute(name string, c Collector, ch chan<- prometheus.Metric) {
 	// Record execution time and success value.
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
 	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+	return err
 }
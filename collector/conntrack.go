@@ -0,0 +1,76 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const conntrackSubsystem = "nf_conntrack"
+
+type conntrackCollector struct {
+	entries *prometheus.Desc
+	limit   *prometheus.Desc
+}
+
+func init() {
+	registerCollector("conntrack", defaultDisabled, NewConntrackCollector)
+}
+
+// NewConntrackCollector returns a new Collector exposing conntrack stats.
+func NewConntrackCollector() (Collector, error) {
+	cc := &conntrackCollector{
+		entries: prometheus.NewDesc(
+			fqName("conntrack", conntrackSubsystem, "entries"),
+			"Number of currently allocated flow entries for connection tracking.",
+			nil, nil,
+		),
+		limit: prometheus.NewDesc(
+			fqName("conntrack", conntrackSubsystem, "limit"),
+			"Maximum size of connection tracking table.",
+			nil, nil,
+		),
+	}
+	return cc, nil
+}
+
+// Update implements the Collector interface.
+func (c *conntrackCollector) Update(ch chan<- prometheus.Metric) error {
+	// The sysctls are absent if the netfilter module isn't loaded.
+	entries, err := readUintFromFile(procFilePath("sys/net/netfilter/nf_conntrack_count"))
+	if os.IsNotExist(err) {
+		log.Debugln("conntrack sysctls not found, skipping")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.entries,
+		prometheus.GaugeValue, float64(entries),
+	)
+
+	limit, err := readUintFromFile(procFilePath("sys/net/netfilter/nf_conntrack_max"))
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.limit,
+		prometheus.GaugeValue, float64(limit),
+	)
+
+	return nil
+}
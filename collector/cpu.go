@@ -15,18 +15,58 @@ package collector
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"math"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 const cpuSubsystem = "cpu"
 
+var (
+	cpuFreqSource      = kingpin.Flag("collector.cpu.freq-source", "Source of the CPU frequency, one of [scaling, cpuinfo]. scaling reads the frequency last requested by the governor, cpuinfo reads the frequency reported by the hardware, which is more accurate but requires root on some kernels. Falls back to scaling if cpuinfo is unreadable.").Default("scaling").Enum("scaling", "cpuinfo")
+	cpuInclude         = kingpin.Flag("collector.cpu.include", "Regexp of CPU indices to export the frequency of.").Default("").String()
+	cpuExclude         = kingpin.Flag("collector.cpu.exclude", "Regexp of CPU indices to not export the frequency of.").Default("").String()
+	cpuAggregate       = kingpin.Flag("collector.cpu.aggregate-freq", "Export the minimum, average and maximum frequency across all CPUs instead of the frequency of each CPU. The scaling maximum and frequency ratio are aggregated as well.").Bool()
+	cpuThermalZoneType = kingpin.Flag("collector.cpu.thermal-zone-type", "Type of the thermal zone to read the CPU temperature from, e.g. cpu-thermal. Falls back to thermal_zone0 if no zone matches. Defaults to thermal_zone0.").Default("").String()
+	cpuFreqMHz         = kingpin.Flag("collector.cpu.freq-mhz", "Additionally export the frequency of each CPU in megahertz.").Bool()
+	cpuGovTunables     = kingpin.Flag("collector.cpu.governor-tunables", "Export the numeric tunables of the active cpufreq governor, e.g. up_threshold of ondemand.").Bool()
+	cpuTimeInState     = kingpin.Flag("collector.cpu.time-in-state", "Export the time spent at each frequency per CPU. Adds a series per CPU and available frequency.").Bool()
+)
+
 type cpuCollector struct {
+	freqSource     string
+	include        *regexp.Regexp
+	exclude        *regexp.Regexp
+	aggregate      bool
+	timeInState    bool
+	freqMHz        bool
+	govTunables    bool
+	zoneType       string
 	cpuTempCelsius *prometheus.Desc
+	temperature    *prometheus.Desc
 	cpuFreqHertz   *prometheus.Desc
+	cpuFreqMHz     *prometheus.Desc
+	cpuFreqMin     *prometheus.Desc
+	cpuFreqAvg     *prometheus.Desc
+	cpuFreqMax     *prometheus.Desc
+	cpuScalingMax  *prometheus.Desc
+	cpuFreqRatio   *prometheus.Desc
+	// Aggregates of cpuScalingMax and cpuFreqRatio across all CPUs.
+	cpuScalingMaxMin *prometheus.Desc
+	cpuFreqRatioAvg  *prometheus.Desc
+	cpuFreqTrans     *prometheus.Desc
+	cpuTimeInState   *prometheus.Desc
+	cpuAvailFreq     *prometheus.Desc
+	cpuGovTunable    *prometheus.Desc
 }
 
 func init() {
@@ -35,53 +75,142 @@ func init() {
 
 // NewCPUCollector returns a new Collector exposing CPU temperature metrics.
 func NewCPUCollector() (Collector, error) {
+	include, err := compileOptionalRegexp(*cpuInclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu include pattern: %s", err)
+	}
+	exclude, err := compileOptionalRegexp(*cpuExclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu exclude pattern: %s", err)
+	}
 	cc := &cpuCollector{
+		freqSource:  *cpuFreqSource,
+		include:     include,
+		exclude:     exclude,
+		aggregate:   *cpuAggregate,
+		timeInState: *cpuTimeInState,
+		freqMHz:     *cpuFreqMHz,
+		govTunables: *cpuGovTunables,
+		zoneType:    *cpuThermalZoneType,
+		temperature: newTemperatureDesc("cpu"),
 		cpuTempCelsius: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, cpuSubsystem, "temperature_celsius"),
+			fqName("cpu", cpuSubsystem, "temperature_celsius"),
 			"CPU temperature in degrees celsius (°C).",
 			nil, nil,
 		),
 		cpuFreqHertz: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, cpuSubsystem, "frequency_hertz"),
+			fqName("cpu", cpuSubsystem, "frequency_hertz"),
 			"CPU Frequency in hertz (Hz).",
 			[]string{"cpu"}, nil,
 		),
+		cpuFreqMHz: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_megahertz"),
+			"CPU Frequency in megahertz (MHz).",
+			[]string{"cpu"}, nil,
+		),
+		cpuFreqMin: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_min_hertz"),
+			"Minimum CPU Frequency across all CPUs in hertz (Hz).",
+			nil, nil,
+		),
+		cpuFreqAvg: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_avg_hertz"),
+			"Average CPU Frequency across all CPUs in hertz (Hz).",
+			nil, nil,
+		),
+		cpuFreqMax: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_max_hertz"),
+			"Maximum CPU Frequency across all CPUs in hertz (Hz).",
+			nil, nil,
+		),
+		cpuScalingMax: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_scaling_max_hertz"),
+			"Maximum CPU frequency the governor may currently select in hertz (Hz). Drops below the hardware maximum while throttling.",
+			[]string{"cpu"}, nil,
+		),
+		cpuFreqRatio: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_ratio"),
+			"Ratio of the current CPU frequency to the maximum frequency the governor may currently select.",
+			[]string{"cpu"}, nil,
+		),
+		cpuScalingMaxMin: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_scaling_max_min_hertz"),
+			"Lowest maximum CPU frequency the governor may currently select across all CPUs in hertz (Hz).",
+			nil, nil,
+		),
+		cpuFreqRatioAvg: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_ratio_avg"),
+			"Average ratio of the current CPU frequency to the maximum frequency the governor may currently select across all CPUs.",
+			nil, nil,
+		),
+		cpuFreqTrans: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "frequency_transitions_total"),
+			"Total number of CPU frequency transitions.",
+			[]string{"cpu"}, nil,
+		),
+		cpuTimeInState: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "time_in_state_seconds_total"),
+			"Total time the CPU spent at a frequency in seconds.",
+			[]string{"cpu", "frequency"}, nil,
+		),
+		cpuAvailFreq: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "available_frequency_hertz"),
+			"Frequency the CPU governor can select in hertz (Hz).",
+			[]string{"frequency"}, nil,
+		),
+		cpuGovTunable: prometheus.NewDesc(
+			fqName("cpu", cpuSubsystem, "governor_tunable"),
+			"Value of a tunable of the active cpufreq governor.",
+			[]string{"governor", "name"}, nil,
+		),
 	}
 	return cc, nil
 }
 
 // Update implements the Collector interface.
 func (c *cpuCollector) Update(ch chan<- prometheus.Metric) error {
-	// Get temperature string from /sys/class/thermal/thermal_zone0/temp and
-	// convert it to float64 value.
-	b, err := ioutil.ReadFile("/sys/class/thermal/thermal_zone0/temp")
+	// Get temperature from /sys/class/thermal/thermal_zone0/temp (or the zone
+	// of the configured type). Skip the sample if it is out of bounds.
+	zone, err := findThermalZone(c.zoneType)
 	if err != nil {
 		return err
 	}
-	temp, err := strconv.ParseFloat(string(bytes.TrimSpace(b)), 64)
+	temp, err := readThermalZoneTemp(filepath.Join(zone, "temp"))
 	if err != nil {
 		return err
 	}
-	temp = temp / 1000
-
-	// Export the metric.
-	ch <- prometheus.MustNewConstMetric(
-		c.cpuTempCelsius,
-		prometheus.GaugeValue, temp,
-	)
+	if validTemp(temp) {
+		// Export the metrics.
+		ch <- prometheus.MustNewConstMetric(
+			c.cpuTempCelsius,
+			prometheus.GaugeValue, temp,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.temperature,
+			prometheus.GaugeValue, temp,
+			"cpu",
+		)
+	} else {
+		log.Warnf("Skipping implausible CPU temperature of %g°C", temp)
+	}
 
 	// Get all the cpus from /sys/devices/system/cpu/cpu*.
-	cpus, err := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*")
+	cpus, err := filepath.Glob(sysFilePath("devices/system/cpu/cpu[0-9]*"))
 	if err != nil {
 		return err
 	}
 
+	var freqs, maxFreqs, ratios []float64
 	for i, cpu := range cpus {
+		id := strconv.Itoa(i)
+		if (c.include != nil && !c.include.MatchString(id)) ||
+			(c.exclude != nil && c.exclude.MatchString(id)) {
+			continue
+		}
+
 		// Get the frequency string from /sys/devices/system/cpu/cpu*/cpufreq/scaling_cur_freq
-		// and convert it to a float64 value.
-		// Use scaling_cur_freq rather than cpuinfo_cur_freq because that seems to be
-		// more accurate, according to the internet.
-		b, err = ioutil.ReadFile(cpu + "/cpufreq/scaling_cur_freq")
+		// (or cpuinfo_cur_freq, if configured) and convert it to a float64 value.
+		b, err := c.readFreq(cpu)
 		if err != nil {
 			return err
 		}
@@ -90,14 +219,240 @@ func (c *cpuCollector) Update(ch chan<- prometheus.Metric) error {
 			return err
 		}
 
-		// Export the metric.
+		// Get the current maximum frequency in kHz from
+		// /sys/devices/system/cpu/cpu*/cpufreq/scaling_max_freq. Skip the
+		// maximum and ratio if it is unreadable, e.g. without a cpufreq
+		// driver.
+		maxFreq, err := readUintFromFile(cpu + "/cpufreq/scaling_max_freq")
+		hasMaxFreq := err == nil
+		if !hasMaxFreq {
+			log.Debugf("Couldn't read the maximum frequency of %s: %s", cpu, err)
+		}
+
+		if err := c.updateStats(ch, cpu, id); err != nil {
+			return err
+		}
+
+		// Aggregated frequencies are exported after all CPUs were read.
+		freqs = append(freqs, freq)
+		if hasMaxFreq {
+			maxFreqs = append(maxFreqs, float64(maxFreq)*1000)
+		}
+		if hasMaxFreq && maxFreq > 0 {
+			ratios = append(ratios, freq/float64(maxFreq))
+		}
+		if c.aggregate {
+			continue
+		}
+
+		// Export the metrics.
 		ch <- prometheus.MustNewConstMetric(
 			c.cpuFreqHertz,
 			prometheus.GaugeValue,
 			freq,
-			strconv.Itoa(i),
+			id,
 		)
+		if c.freqMHz {
+			// The frequency is read in kHz.
+			ch <- prometheus.MustNewConstMetric(
+				c.cpuFreqMHz,
+				prometheus.GaugeValue,
+				freq/1000,
+				id,
+			)
+		}
+		if hasMaxFreq {
+			ch <- prometheus.MustNewConstMetric(
+				c.cpuScalingMax,
+				prometheus.GaugeValue,
+				float64(maxFreq)*1000,
+				id,
+			)
+		}
+		// Both frequencies are read in kHz. Skip the ratio if the maximum
+		// isn't known.
+		if hasMaxFreq && maxFreq > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.cpuFreqRatio,
+				prometheus.GaugeValue,
+				ratios[len(ratios)-1],
+				id,
+			)
+		}
+	}
+
+	if c.aggregate && len(freqs) > 0 {
+		min, avg, max := aggregate(freqs)
+		ch <- prometheus.MustNewConstMetric(c.cpuFreqMin, prometheus.GaugeValue, min)
+		ch <- prometheus.MustNewConstMetric(c.cpuFreqAvg, prometheus.GaugeValue, avg)
+		ch <- prometheus.MustNewConstMetric(c.cpuFreqMax, prometheus.GaugeValue, max)
+
+		// The lowest cap is the one limiting the system the most.
+		if len(maxFreqs) > 0 {
+			min, _, _ = aggregate(maxFreqs)
+			ch <- prometheus.MustNewConstMetric(c.cpuScalingMaxMin, prometheus.GaugeValue, min)
+		}
+		if len(ratios) > 0 {
+			_, avg, _ = aggregate(ratios)
+			ch <- prometheus.MustNewConstMetric(c.cpuFreqRatioAvg, prometheus.GaugeValue, avg)
+		}
 	}
 
+	if err := c.updateAvailableFreqs(ch); err != nil {
+		return err
+	}
+	if c.govTunables {
+		return c.updateGovernorTunables(ch)
+	}
 	return nil
 }
+
+// updateGovernorTunables exports the numeric tunables of the governor of cpu0.
+// Depending on the driver, they are either per policy in the cpufreq
+// directory of the CPU, or global in /sys/devices/system/cpu/cpufreq.
+// Governors like performance don't have tunables.
+func (c *cpuCollector) updateGovernorTunables(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(sysFilePath("devices/system/cpu/cpu0/cpufreq/scaling_governor"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	governor := strings.TrimSpace(string(b))
+
+	var (
+		dir   string
+		files []os.FileInfo
+	)
+	for _, parent := range []string{"devices/system/cpu/cpu0/cpufreq", "devices/system/cpu/cpufreq"} {
+		dir = filepath.Join(sysFilePath(parent), governor)
+		if files, err = ioutil.ReadDir(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			// Some tunables are write-only.
+			log.Debugf("Couldn't read governor tunable %s: %s", file.Name(), err)
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.cpuGovTunable,
+			prometheus.GaugeValue, value,
+			governor, file.Name(),
+		)
+	}
+	return nil
+}
+
+// updateAvailableFreqs exports the frequencies the governor can select. All
+// cores of the Pi share a single cpufreq policy, so they are read from cpu0.
+// Drivers without a frequency table don't provide them.
+func (c *cpuCollector) updateAvailableFreqs(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(sysFilePath("devices/system/cpu/cpu0/cpufreq/scaling_available_frequencies"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// 600000 700000 800000 => 600000000 700000000 800000000
+	for _, field := range strings.Fields(string(b)) {
+		freq, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid available frequency: %q", field)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.cpuAvailFreq,
+			prometheus.GaugeValue,
+			float64(freq)*1000,
+			strconv.FormatUint(freq*1000, 10),
+		)
+	}
+	return nil
+}
+
+// updateStats exports the frequency statistics of the given cpu. They are only
+// available if the kernel is built with CONFIG_CPU_FREQ_STAT.
+func (c *cpuCollector) updateStats(ch chan<- prometheus.Metric, cpu, id string) error {
+	trans, err := readUintFromFile(cpu + "/cpufreq/stats/total_trans")
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.cpuFreqTrans,
+		prometheus.CounterValue,
+		float64(trans),
+		id,
+	)
+
+	if !c.timeInState {
+		return nil
+	}
+	b, err := ioutil.ReadFile(cpu + "/cpufreq/stats/time_in_state")
+	if err != nil {
+		return err
+	}
+	// One line per frequency in kHz with the time in 10ms units.
+	// 600000 12345
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		freq, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		t, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.cpuTimeInState,
+			prometheus.CounterValue,
+			t/userHZ,
+			id, strconv.FormatUint(freq*1000, 10),
+		)
+	}
+	return nil
+}
+
+// aggregate returns the minimum, average and maximum of the given non-empty
+// values.
+func aggregate(values []float64) (min, avg, max float64) {
+	min, max = values[0], values[0]
+	var sum float64
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+		sum += v
+	}
+	return min, sum / float64(len(values)), max
+}
+
+// readFreq reads the current frequency of the given cpu from the configured
+// source. If cpuinfo_cur_freq can't be read, scaling_cur_freq is used instead.
+func (c *cpuCollector) readFreq(cpu string) ([]byte, error) {
+	if c.freqSource == "cpuinfo" {
+		b, err := ioutil.ReadFile(cpu + "/cpufreq/cpuinfo_cur_freq")
+		if err == nil {
+			return b, nil
+		}
+		log.Debugf("Couldn't read cpuinfo_cur_freq, falling back to scaling_cur_freq: %s", err)
+	}
+	return ioutil.ReadFile(cpu + "/cpufreq/scaling_cur_freq")
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type cpustatCollector struct {
+	contextSwitches *prometheus.Desc
+	interrupts      *prometheus.Desc
+}
+
+func init() {
+	registerCollector("cpustat", defaultEnabled, NewCPUStatCollector)
+}
+
+// NewCPUStatCollector returns a new Collector exposing kernel activity counters
+// from /proc/stat.
+func NewCPUStatCollector() (Collector, error) {
+	cc := &cpustatCollector{
+		contextSwitches: prometheus.NewDesc(
+			fqName("cpustat", "", "context_switches_total"),
+			"Total number of context switches.",
+			nil, nil,
+		),
+		interrupts: prometheus.NewDesc(
+			fqName("cpustat", "", "interrupts_total"),
+			"Total number of interrupts serviced.",
+			nil, nil,
+		),
+	}
+	return cc, nil
+}
+
+// Update implements the Collector interface.
+func (c *cpustatCollector) Update(ch chan<- prometheus.Metric) error {
+	f, err := os.Open(procFilePath("stat"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// ctxt 12345
+	// intr 67890 0 0 ...
+	descs := map[string]*prometheus.Desc{
+		"ctxt": c.contextSwitches,
+		"intr": c.interrupts,
+	}
+	scanner := bufio.NewScanner(f)
+	// The intr line lists every interrupt and may exceed the default buffer.
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		desc, ok := descs[fields[0]]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value in stat: %s", fields[0], err)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue, v,
+		)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const deviceTreeSubsystem = "device_tree"
+
+type deviceTreeCollector struct {
+	compatible *prometheus.Desc
+	overlay    *prometheus.Desc
+}
+
+func init() {
+	registerCollector("devicetree", defaultEnabled, NewDeviceTreeCollector)
+}
+
+// NewDeviceTreeCollector returns a new Collector exposing the compatible
+// strings of the device tree and the overlays applied by the firmware.
+func NewDeviceTreeCollector() (Collector, error) {
+	dc := &deviceTreeCollector{
+		compatible: prometheus.NewDesc(
+			fqName("devicetree", deviceTreeSubsystem, "compatible"),
+			"Compatible string of the device tree root node.",
+			[]string{"value"}, nil,
+		),
+		overlay: prometheus.NewDesc(
+			fqName("devicetree", deviceTreeSubsystem, "overlay"),
+			"Device tree overlay applied by the firmware at boot.",
+			[]string{"name"}, nil,
+		),
+	}
+	return dc, nil
+}
+
+// Update implements the Collector interface.
+func (c *deviceTreeCollector) Update(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(procFilePath("device-tree/compatible"))
+	if os.IsNotExist(err) {
+		// Not booted with a device tree.
+		log.Debugf("No device tree found: %s", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The property is a list of NUL-terminated strings, e.g.
+	// "raspberrypi,4-model-b\x00brcm,bcm2711\x00".
+	for _, value := range bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0}) {
+		if len(value) == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.compatible,
+			prometheus.GaugeValue, 1,
+			string(value),
+		)
+	}
+
+	// The firmware adds a property named after each overlay it applied to
+	// /chosen/overlays. Older firmware doesn't.
+	files, err := ioutil.ReadDir(procFilePath("device-tree/chosen/overlays"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, file := range files {
+		// Every node has a name property.
+		if file.IsDir() || file.Name() == "name" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.overlay,
+			prometheus.GaugeValue, 1,
+			file.Name(),
+		)
+	}
+
+	return nil
+}
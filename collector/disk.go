@@ -0,0 +1,112 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const diskSubsystem = "disk"
+
+var (
+	diskIgnoredDevices = kingpin.Flag("collector.disk.ignored-devices", "Regexp of devices to ignore for diskstats.").Default(`^(ram|loop|mmcblk\d+p|sd[a-z]+)\d+$`).String()
+)
+
+type diskCollector struct {
+	ignoredDevices *regexp.Regexp
+	ioTimeSeconds  *prometheus.Desc
+	ioNow          *prometheus.Desc
+}
+
+func init() {
+	registerCollector("disk", defaultEnabled, NewDiskCollector)
+}
+
+// NewDiskCollector returns a new Collector exposing disk I/O stats from
+// /proc/diskstats.
+func NewDiskCollector() (Collector, error) {
+	ignoredDevices, err := regexp.Compile(*diskIgnoredDevices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignored devices pattern: %s", err)
+	}
+	dc := &diskCollector{
+		ignoredDevices: ignoredDevices,
+		ioTimeSeconds: prometheus.NewDesc(
+			fqName("disk", diskSubsystem, "io_time_seconds_total"),
+			"Total seconds spent doing I/Os.",
+			[]string{"device"}, nil,
+		),
+		ioNow: prometheus.NewDesc(
+			fqName("disk", diskSubsystem, "io_now"),
+			"Number of I/Os currently in progress.",
+			[]string{"device"}, nil,
+		),
+	}
+	return dc, nil
+}
+
+// Update implements the Collector interface.
+func (c *diskCollector) Update(ch chan<- prometheus.Metric) error {
+	f, err := os.Open(procFilePath("diskstats"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The first three fields are major, minor and device name, followed
+		// by the stats. The 9th stat is the number of I/Os in progress, the
+		// 10th the milliseconds spent doing I/Os.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			return fmt.Errorf("invalid diskstats line: %q", scanner.Text())
+		}
+		device := fields[2]
+		if c.ignoredDevices.MatchString(device) {
+			continue
+		}
+
+		ioNow, err := strconv.ParseFloat(fields[11], 64)
+		if err != nil {
+			return err
+		}
+		ioTimeMs, err := strconv.ParseFloat(fields[12], 64)
+		if err != nil {
+			return err
+		}
+
+		// Export the metrics.
+		ch <- prometheus.MustNewConstMetric(
+			c.ioNow,
+			prometheus.GaugeValue, ioNow,
+			device,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ioTimeSeconds,
+			prometheus.CounterValue, ioTimeMs/1000,
+			device,
+		)
+	}
+
+	return scanner.Err()
+}
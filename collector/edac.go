@@ -0,0 +1,92 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const memorySubsystem = "memory"
+
+type edacCollector struct {
+	correctable   *prometheus.Desc
+	uncorrectable *prometheus.Desc
+}
+
+func init() {
+	registerCollector("edac", defaultDisabled, NewEDACCollector)
+}
+
+// NewEDACCollector returns a new Collector exposing the memory errors
+// detected by the EDAC memory controllers.
+func NewEDACCollector() (Collector, error) {
+	ec := &edacCollector{
+		correctable: prometheus.NewDesc(
+			fqName("edac", memorySubsystem, "correctable_errors_total"),
+			"Number of correctable memory errors detected by the memory controller.",
+			[]string{"controller"}, nil,
+		),
+		uncorrectable: prometheus.NewDesc(
+			fqName("edac", memorySubsystem, "uncorrectable_errors_total"),
+			"Number of uncorrectable memory errors detected by the memory controller.",
+			[]string{"controller"}, nil,
+		),
+	}
+	return ec, nil
+}
+
+// Update implements the Collector interface.
+func (c *edacCollector) Update(ch chan<- prometheus.Metric) error {
+	// Get all memory controllers from /sys/devices/system/edac/mc/mc*.
+	controllers, err := filepath.Glob(sysFilePath("devices/system/edac/mc/mc[0-9]*"))
+	if err != nil {
+		return err
+	}
+	if len(controllers) == 0 {
+		// Most boards don't have ECC memory.
+		log.Debugf("No EDAC memory controller found")
+		return nil
+	}
+
+	for _, controller := range controllers {
+		id := strings.TrimPrefix(filepath.Base(controller), "mc")
+
+		ce, err := readUintFromFile(filepath.Join(controller, "ce_count"))
+		if err != nil {
+			return err
+		}
+		ue, err := readUintFromFile(filepath.Join(controller, "ue_count"))
+		if err != nil {
+			return err
+		}
+
+		// Export the metrics.
+		ch <- prometheus.MustNewConstMetric(
+			c.correctable,
+			prometheus.CounterValue, float64(ce),
+			id,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.uncorrectable,
+			prometheus.CounterValue, float64(ue),
+			id,
+		)
+	}
+
+	return nil
+}
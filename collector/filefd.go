@@ -0,0 +1,88 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const filefdSubsystem = "filefd"
+
+type filefdCollector struct {
+	allocated *prometheus.Desc
+	maximum   *prometheus.Desc
+}
+
+func init() {
+	registerCollector("filefd", defaultEnabled, NewFileFDCollector)
+}
+
+// NewFileFDCollector returns a new Collector exposing file descriptor stats
+// from /proc/sys/fs/file-nr.
+func NewFileFDCollector() (Collector, error) {
+	fc := &filefdCollector{
+		allocated: prometheus.NewDesc(
+			fqName("filefd", filefdSubsystem, "allocated"),
+			"Number of allocated file descriptors.",
+			nil, nil,
+		),
+		maximum: prometheus.NewDesc(
+			fqName("filefd", filefdSubsystem, "maximum"),
+			"Maximum number of file descriptors.",
+			nil, nil,
+		),
+	}
+	return fc, nil
+}
+
+// Update implements the Collector interface.
+func (c *filefdCollector) Update(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(procFilePath("sys/fs/file-nr"))
+	if err != nil {
+		return err
+	}
+
+	// The allocated, the allocated but unused (always 0 since Linux 2.6) and
+	// the maximum number of file descriptors.
+	// 1024	0	9223372036854775807
+	fields := strings.Fields(string(b))
+	if len(fields) != 3 {
+		return fmt.Errorf("invalid file-nr: %q", b)
+	}
+	allocated, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return err
+	}
+	maximum, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return err
+	}
+
+	// Export the metrics.
+	ch <- prometheus.MustNewConstMetric(
+		c.allocated,
+		prometheus.GaugeValue, allocated,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.maximum,
+		prometheus.GaugeValue, maximum,
+	)
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+type freqCapCollector struct {
+	capRatio *prometheus.Desc
+}
+
+func init() {
+	registerCollector("freqcap", defaultDisabled, NewFreqCapCollector)
+}
+
+// NewFreqCapCollector returns a new Collector exposing how far the frequency
+// of each CPU is capped below its hardware maximum.
+func NewFreqCapCollector() (Collector, error) {
+	fc := &freqCapCollector{
+		capRatio: prometheus.NewDesc(
+			fqName("freqcap", cpuSubsystem, "frequency_cap_ratio"),
+			"Ratio of the maximum frequency the governor may currently select to the hardware maximum frequency of the CPU (0-1). Caps are set by thermal throttling, the user or the governor.",
+			[]string{"cpu"}, nil,
+		),
+	}
+	return fc, nil
+}
+
+// Update implements the Collector interface.
+func (c *freqCapCollector) Update(ch chan<- prometheus.Metric) error {
+	cpus, err := filepath.Glob(sysFilePath("devices/system/cpu/cpu[0-9]*"))
+	if err != nil {
+		return err
+	}
+
+	for _, cpu := range cpus {
+		// Both frequencies are read in kHz from
+		// /sys/devices/system/cpu/cpu*/cpufreq. Skip CPUs without them.
+		maxFreq, err := readUintFromFile(filepath.Join(cpu, "cpufreq/cpuinfo_max_freq"))
+		if os.IsNotExist(err) {
+			log.Debugf("No cpufreq found for %s, skipping frequency cap", cpu)
+			continue
+		} else if err != nil {
+			return err
+		}
+		cappedFreq, err := readUintFromFile(filepath.Join(cpu, "cpufreq/scaling_max_freq"))
+		if os.IsNotExist(err) {
+			log.Debugf("No scaling_max_freq found for %s, skipping frequency cap", cpu)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if maxFreq == 0 || cappedFreq > maxFreq {
+			continue
+		}
+
+		// Export the metric.
+		ch <- prometheus.MustNewConstMetric(
+			c.capRatio,
+			prometheus.GaugeValue,
+			float64(cappedFreq)/float64(maxFreq),
+			strings.TrimPrefix(filepath.Base(cpu), "cpu"),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const gpioSubsystem = "gpio"
+
+// gpioChip is a GPIO controller in /sys/class/gpio, which numbers its lines
+// starting at base.
+type gpioChip struct {
+	label string
+	base  int
+	ngpio int
+}
+
+type gpioCollector struct {
+	value     *prometheus.Desc
+	direction *prometheus.Desc
+}
+
+func init() {
+	registerCollector("gpio", defaultDisabled, NewGPIOCollector)
+}
+
+// NewGPIOCollector returns a new Collector exposing the state of the GPIO pins
+// exported via /sys/class/gpio.
+func NewGPIOCollector() (Collector, error) {
+	gc := &gpioCollector{
+		value: prometheus.NewDesc(
+			fqName("gpio", gpioSubsystem, "value"),
+			"Value of an exported GPIO pin.",
+			[]string{"chip", "pin"}, nil,
+		),
+		direction: prometheus.NewDesc(
+			fqName("gpio", gpioSubsystem, "direction"),
+			"Direction of an exported GPIO pin.",
+			[]string{"chip", "pin", "direction"}, nil,
+		),
+	}
+	return gc, nil
+}
+
+// Update implements the Collector interface.
+func (c *gpioCollector) Update(ch chan<- prometheus.Metric) error {
+	chips, err := readGPIOChips()
+	if err != nil {
+		return err
+	}
+
+	// Only pins exported by the user are present, so there is no need to
+	// enumerate all of them.
+	pins, err := filepath.Glob(sysFilePath("class/gpio/gpio[0-9]*"))
+	if err != nil {
+		return err
+	}
+	for _, pin := range pins {
+		num, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(pin), "gpio"))
+		if err != nil {
+			continue
+		}
+		// The global number depends on the kernel, e.g. GPIO 17 is gpio529 on
+		// recent kernels, so the pin is exported relative to its chip.
+		chip, offset := gpioChipOf(chips, num)
+
+		value, err := readUintFromFile(filepath.Join(pin, "value"))
+		if os.IsNotExist(err) {
+			// The pin was unexported in the meantime.
+			continue
+		} else if err != nil {
+			return err
+		}
+		direction, err := ioutil.ReadFile(filepath.Join(pin, "direction"))
+		if err != nil {
+			return err
+		}
+
+		// Export the metrics.
+		ch <- prometheus.MustNewConstMetric(
+			c.value,
+			prometheus.GaugeValue, float64(value),
+			chip, strconv.Itoa(offset),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.direction,
+			prometheus.GaugeValue, 1,
+			chip, strconv.Itoa(offset), strings.TrimSpace(string(direction)),
+		)
+	}
+
+	return nil
+}
+
+// readGPIOChips reads the GPIO controllers from /sys/class/gpio.
+func readGPIOChips() ([]gpioChip, error) {
+	dirs, err := filepath.Glob(sysFilePath("class/gpio/gpiochip*"))
+	if err != nil {
+		return nil, err
+	}
+	var chips []gpioChip
+	for _, dir := range dirs {
+		base, err := readUintFromFile(filepath.Join(dir, "base"))
+		if err != nil {
+			return nil, err
+		}
+		ngpio, err := readUintFromFile(filepath.Join(dir, "ngpio"))
+		if err != nil {
+			return nil, err
+		}
+		label, err := ioutil.ReadFile(filepath.Join(dir, "label"))
+		if err != nil {
+			return nil, err
+		}
+		chips = append(chips, gpioChip{
+			label: strings.TrimSpace(string(label)),
+			base:  int(base),
+			ngpio: int(ngpio),
+		})
+	}
+	return chips, nil
+}
+
+// gpioChipOf returns the label of the chip the given global GPIO number
+// belongs to and the offset of the pin on it. Pins of unknown chips keep their
+// global number and an empty label.
+func gpioChipOf(chips []gpioChip, num int) (string, int) {
+	for _, chip := range chips {
+		if num >= chip.base && num < chip.base+chip.ngpio {
+			return chip.label, num - chip.base
+		}
+	}
+	return "", num
+}
@@ -14,32 +14,99 @@
 package collector
 
 import (
+	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 const gpuSubsystem = "gpu"
 
 // The vcgencmd components to be considered part of the gpu.
-// The clock frequency of these will be exported.
+// The clock frequency of these is exported by default.
 func getGpuComponents() []string {
 	return []string{"core", "h264", "v3d"}
 }
 
+// isGpuComponent reports whether the given clock belongs to the gpu.
+func isGpuComponent(clock string) bool {
+	for _, component := range getGpuComponents() {
+		if component == clock {
+			return true
+		}
+	}
+	return false
+}
+
+// overVoltageRails maps the over_voltage keys of config.txt to the voltage rail
+// they offset.
+var overVoltageRails = map[string]string{
+	"over_voltage":         "core",
+	"over_voltage_min":     "core_min",
+	"over_voltage_sdram":   "sdram",
+	"over_voltage_sdram_c": "sdram_c",
+	"over_voltage_sdram_i": "sdram_i",
+	"over_voltage_sdram_p": "sdram_p",
+}
+
 var (
 	// /opt/vc/bin/vcgencmd for RaspiOS 32bit
 	// /usr/bin/vcgencmd for RaspiOS 64bit
-	vcgencmd = kingpin.Flag("vcgencmd", "vcgencmd including path.").Default("/opt/vc/bin/vcgencmd").String()
+	vcgencmd = kingpin.Flag("vcgencmd", "vcgencmd including path. If it isn't executable, the known locations of RaspiOS 32bit and 64bit are tried.").Default("/opt/vc/bin/vcgencmd").String()
+
+	gpuSudo       = kingpin.Flag("collector.gpu.sudo", "Execute vcgencmd via sudo -n, for subcommands requiring elevated privileges. sudo must be configured to not prompt for a password.").Bool()
+	gpuClocks     = kingpin.Flag("collector.gpu.clocks", "Comma separated list of clocks to pass to vcgencmd measure_clock, e.g. arm,core,sdram,emmc. Clocks which can't be measured are skipped with a warning.").Default(strings.Join(getGpuComponents(), ",")).String()
+	gpuTempAlpha  = kingpin.Flag("collector.gpu.temp-ewma-alpha", "Smoothing factor (0-1] of the exponentially-weighted moving average of the GPU temperature. Higher values follow the raw temperature more closely. Disabled if 0.").Default("0").Float64()
+	gpuCodecIdle  = kingpin.Flag("collector.gpu.codec-idle-hertz", "Clock of the H.264 block at or below which it is considered idle. The firmware gates the clock while the block is unused.").Default("0").Float64()
+	gpuForce      = kingpin.Flag("collector.gpu.force", "Enable the gpu collector even if the device tree model isn't a Raspberry Pi.").Bool()
+	gpuInterval   = kingpin.Flag("collector.gpu.interval", "Minimum interval between executions of vcgencmd. Scrapes within the interval are served the cached metrics of the previous one. Disabled if 0.").Default("0s").Duration()
+	gpuExtraTemps = kingpin.Flag("collector.gpu.extra-temps", "Comma separated list of additional sensors to pass to vcgencmd measure_temp, e.g. pmic on a Pi 5.").Default("").String()
+)
+
+// gpuEWMA is the moving average of the temperature across scrapes. It is shared
+// by all instances of the collector, which are also created for filtered
+// requests, so the average doesn't restart with each of them.
+var (
+	gpuEWMAMu  sync.Mutex
+	gpuEWMA    float64
+	gpuHasEWMA bool
+)
+
+// gpuCached holds the metrics collected at gpuCachedAt, served until
+// --collector.gpu.interval elapsed. Like the moving average, the cache is
+// shared by all instances of the collector.
+var (
+	gpuCacheMu  sync.Mutex
+	gpuCached   []prometheus.Metric
+	gpuCachedAt time.Time
 )
 
 type gpuCollector struct {
 	vcgencmd       string
+	sudo           bool
+	extraTemps     []string
+	clocks         []string
+	tempAlpha      float64
+	codecIdle      float64
+	interval       time.Duration
 	gpuTempCelsius *prometheus.Desc
+	temperature    *prometheus.Desc
+	gpuTempEWMA    *prometheus.Desc
 	gpuFreqHertz   *prometheus.Desc
+	gpuClockConfig *prometheus.Desc
+	gpuRelocUsed   *prometheus.Desc
+	gpuRelocTotal  *prometheus.Desc
+	codecActive    *prometheus.Desc
+	overVoltage    *prometheus.Desc
+
+	// warnedClocks holds the clocks a failure was already logged for.
+	warnedClocks sync.Map
 }
 
 func init() {
@@ -48,79 +115,348 @@ func init() {
 
 // NewGPUCollector returns a new Collector exposing GPU temperature metrics.
 func NewGPUCollector() (Collector, error) {
+	if *gpuTempAlpha < 0 || *gpuTempAlpha > 1 {
+		return nil, fmt.Errorf("--collector.gpu.temp-ewma-alpha must be between 0 and 1")
+	}
+	// Other boards don't have vcgencmd, so the collector would always fail.
+	if model := readModel(); !*gpuForce && !strings.HasPrefix(model, "Raspberry Pi") {
+		return nil, errUnavailable(fmt.Errorf("not a Raspberry Pi (model %q), use --collector.gpu.force to enable it anyway", model))
+	}
+	if *gpuSudo {
+		if _, err := exec.LookPath("sudo"); err != nil {
+			return nil, fmt.Errorf("sudo is required by --collector.gpu.sudo: %s", err)
+		}
+	}
 	gc := &gpuCollector{
-		vcgencmd: *vcgencmd,
+		vcgencmd:    vcgencmdPath(),
+		sudo:        *gpuSudo,
+		extraTemps:  splitList(*gpuExtraTemps),
+		clocks:      splitList(*gpuClocks),
+		tempAlpha:   *gpuTempAlpha,
+		codecIdle:   *gpuCodecIdle,
+		interval:    *gpuInterval,
+		temperature: newTemperatureDesc("gpu"),
 		gpuTempCelsius: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, gpuSubsystem, "temperature_celsius"),
+			fqName("gpu", gpuSubsystem, "temperature_celsius"),
 			"GPU temperature in degrees celsius (°C).",
 			nil, nil,
 		),
+		gpuTempEWMA: prometheus.NewDesc(
+			fqName("gpu", gpuSubsystem, "temperature_celsius_ewma"),
+			"Exponentially-weighted moving average of the GPU temperature in degrees celsius (°C).",
+			nil, nil,
+		),
 		gpuFreqHertz: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, gpuSubsystem, "frequency_hertz"),
+			fqName("gpu", gpuSubsystem, "frequency_hertz"),
 			"GPU frequency in hertz (Hz).",
 			[]string{"component"}, nil,
 		),
+		gpuClockConfig: prometheus.NewDesc(
+			fqName("gpu", gpuSubsystem, "clock_config_hertz"),
+			"GPU frequency configured in config.txt in hertz (Hz).",
+			[]string{"component", "bound"}, nil,
+		),
+		gpuRelocUsed: prometheus.NewDesc(
+			fqName("gpu", gpuSubsystem, "reloc_used_bytes"),
+			"Used memory of the GPU relocatable heap in bytes.",
+			nil, nil,
+		),
+		gpuRelocTotal: prometheus.NewDesc(
+			fqName("gpu", gpuSubsystem, "reloc_total_bytes"),
+			"Size of the GPU relocatable heap in bytes.",
+			nil, nil,
+		),
+		codecActive: prometheus.NewDesc(
+			fqName("gpu", "codec", "active"),
+			"Whether the clock of the hardware codec is above idle, i.e. the codec is in use.",
+			[]string{"codec"}, nil,
+		),
+		overVoltage: prometheus.NewDesc(
+			fqName("gpu", "config", "over_voltage"),
+			"Voltage offset of a rail configured in config.txt, in steps of 25mV.",
+			[]string{"rail"}, nil,
+		),
 	}
 	return gc, nil
 }
 
 // Update implements the Collector interface.
 func (c *gpuCollector) Update(ch chan<- prometheus.Metric) error {
+	if c.interval <= 0 {
+		return c.update(ch)
+	}
+
+	gpuCacheMu.Lock()
+	defer gpuCacheMu.Unlock()
+	if gpuCached == nil || time.Since(gpuCachedAt) >= c.interval {
+		metrics := make(chan prometheus.Metric)
+		done := make(chan []prometheus.Metric)
+		go func() {
+			var collected []prometheus.Metric
+			for m := range metrics {
+				collected = append(collected, m)
+			}
+			done <- collected
+		}()
+		err := c.update(metrics)
+		close(metrics)
+		collected := <-done
+		if err != nil {
+			return err
+		}
+		gpuCached, gpuCachedAt = collected, time.Now()
+	}
+
+	// Export the metrics.
+	for _, m := range gpuCached {
+		ch <- m
+	}
+	return nil
+}
+
+// update executes vcgencmd and exports the metrics.
+func (c *gpuCollector) update(ch chan<- prometheus.Metric) error {
+	// Read the temperatures and clocks concurrently and export them once all
+	// reads are done.
+	var (
+		temp       float64
+		extraTemps = make([]*float64, len(c.extraTemps))
+		components = c.clocks
+		freqs      = make([]*float64, len(components))
+		fns        []func() error
+	)
+
 	// Get temperature string by executing /opt/vc/bin/vcgencmd measure_temp
 	// and convert it to float64 value.
-	cmd := exec.Command(c.vcgencmd, "measure_temp")
-	stdout, err := cmd.Output()
-	if err != nil {
+	fns = append(fns, func() error {
+		stdout, err := c.output("measure_temp")
+		if err != nil {
+			return err
+		}
+		temp, err = parseTemp(stdout)
 		return err
+	})
+
+	// Get the temperatures of additional sensors. Not all boards have all
+	// sensors, so failures are skipped.
+	for i, sensor := range c.extraTemps {
+		i, sensor := i, sensor
+		fns = append(fns, func() error {
+			stdout, err := c.output("measure_temp", sensor)
+			if err != nil {
+				log.Debugf("Couldn't read temperature of sensor %s: %s", sensor, err)
+				return nil
+			}
+			temp, err := parseTemp(stdout)
+			if err != nil {
+				log.Debugf("Couldn't parse temperature of sensor %s: %s", sensor, err)
+				return nil
+			}
+			if !validTemp(temp) {
+				log.Warnf("Skipping implausible temperature of sensor %s of %g°C", sensor, temp)
+				return nil
+			}
+			extraTemps[i] = &temp
+			return nil
+		})
 	}
 
-	// temp=55.3'C => 55.3
-	tempStr := string(stdout)
-	idx := strings.IndexByte(tempStr, '=')
-	if idx != -1 {
-		tempStr = tempStr[idx+1:]
+	for i, component := range components {
+		i, component := i, component
+		fns = append(fns, func() error {
+			// Get frequency string by executing vcgencmd and
+			// convert it to float64 value.
+			stdout, err := c.output("measure_clock", component)
+			if err == nil {
+				var freq float64
+				if freq, err = parseClock(stdout); err == nil {
+					freqs[i] = &freq
+					return nil
+				}
+			}
+			if vcgencmdMissing(err) {
+				return err
+			}
+			// Unknown clocks are skipped, the warning is only logged once.
+			if _, warned := c.warnedClocks.LoadOrStore(component, true); warned {
+				log.Debugf("Couldn't measure clock %s: %s", component, err)
+			} else {
+				log.Warnf("Couldn't measure clock %s, skipping it: %s", component, err)
+			}
+			return nil
+		})
 	}
-	tempStr = strings.TrimSuffix(tempStr, "'C\n")
-	temp, err := strconv.ParseFloat(tempStr, 64)
-	if err != nil {
-		return err
+
+	if err := runConcurrently(fns...); err != nil {
+		return vcgencmdUnavailable(err)
 	}
 
-	// Export the metric.
-	ch <- prometheus.MustNewConstMetric(
-		c.gpuTempCelsius,
-		prometheus.GaugeValue, temp,
-	)
+	// Export the metrics. Skip the temperature if it is out of bounds, so it
+	// doesn't skew the moving average either.
+	if validTemp(temp) {
+		ch <- prometheus.MustNewConstMetric(
+			c.gpuTempCelsius,
+			prometheus.GaugeValue, temp,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.temperature,
+			prometheus.GaugeValue, temp,
+			"gpu",
+		)
+		if c.tempAlpha > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.gpuTempEWMA,
+				prometheus.GaugeValue, c.updateEWMA(temp),
+			)
+		}
+	} else {
+		log.Warnf("Skipping implausible GPU temperature of %g°C", temp)
+	}
+	for i, sensor := range c.extraTemps {
+		if extraTemps[i] == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.temperature,
+			prometheus.GaugeValue, *extraTemps[i],
+			sensor,
+		)
+	}
+	for i, component := range components {
+		if freqs[i] == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.gpuFreqHertz,
+			prometheus.GaugeValue,
+			*freqs[i],
+			component,
+		)
 
-	for _, component := range getGpuComponents() {
-		// Get frequency string by executing vcgencmd and
-		// convert it to float64 value.
-		cmd = exec.Command(c.vcgencmd, "measure_clock", component)
-		stdout, err := cmd.Output()
+		// The firmware doesn't report the codec load, but the clock of the
+		// codec block tells whether it is used at all.
+		if component == "h264" {
+			ch <- prometheus.MustNewConstMetric(
+				c.codecActive,
+				prometheus.GaugeValue,
+				boolToFloat(*freqs[i] > c.codecIdle),
+				component,
+			)
+		}
+	}
+
+	c.updateReloc(ch)
+
+	// Get the configured frequencies. Not all systems allow reading the
+	// config, so failures are skipped.
+	stdout, err := c.output("get_config", "int")
+	if err != nil {
+		log.Debugf("Couldn't read config: %s", err)
+		return nil
+	}
+	config := parseConfig(stdout)
+	for _, component := range components {
+		// core_freq=500 => 500. gpu_freq sets the frequency of all gpu
+		// components, unless they are configured individually.
+		value, ok := config[component+"_freq"]
+		if !ok && isGpuComponent(component) {
+			value, ok = config["gpu_freq"]
+		}
+		if !ok {
+			continue
+		}
+		freqMHz, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			return err
 		}
 
-		// frequency(1)=400000000 => 400000000
-		freqStr := string(stdout)
-		idx = strings.IndexByte(freqStr, '=')
-		if idx != -1 {
-			freqStr = freqStr[idx+1:]
+		// Export the metric.
+		ch <- prometheus.MustNewConstMetric(
+			c.gpuClockConfig,
+			prometheus.GaugeValue,
+			freqMHz*1e6,
+			component, "configured",
+		)
+	}
+
+	for key, rail := range overVoltageRails {
+		value, ok := config[key]
+		if !ok {
+			continue
 		}
-		freqStr = strings.TrimSuffix(freqStr, "\n")
-		freq, err := strconv.ParseFloat(freqStr, 64)
+		steps, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			return err
 		}
 
 		// Export the metric.
 		ch <- prometheus.MustNewConstMetric(
-			c.gpuFreqHertz,
-			prometheus.GaugeValue,
-			freq,
-			component,
+			c.overVoltage,
+			prometheus.GaugeValue, steps,
+			rail,
 		)
 	}
 
 	return nil
 }
+
+// updateEWMA adds the given temperature to the moving average and returns it.
+// The average starts at the first temperature.
+func (c *gpuCollector) updateEWMA(temp float64) float64 {
+	gpuEWMAMu.Lock()
+	defer gpuEWMAMu.Unlock()
+	if !gpuHasEWMA {
+		gpuEWMA, gpuHasEWMA = temp, true
+	} else {
+		gpuEWMA = c.tempAlpha*temp + (1-c.tempAlpha)*gpuEWMA
+	}
+	return gpuEWMA
+}
+
+// output executes vcgencmd with the given arguments, via sudo if configured.
+func (c *gpuCollector) output(args ...string) ([]byte, error) {
+	if c.sudo {
+		return vcgencmdSudoOutput(c.vcgencmd, args...)
+	}
+	return vcgencmdOutput(c.vcgencmd, args...)
+}
+
+// updateReloc exports the size and usage of the relocatable heap. Older
+// firmware doesn't support these arguments, so failures are skipped.
+func (c *gpuCollector) updateReloc(ch chan<- prometheus.Metric) {
+	var values [2]float64
+	for i, arg := range []string{"reloc_total", "reloc"} {
+		stdout, err := c.output("get_mem", arg)
+		if err != nil {
+			log.Debugf("Couldn't read %s: %s", arg, err)
+			return
+		}
+		if values[i], err = parseMem(stdout); err != nil {
+			log.Debugf("Couldn't parse %s: %s", arg, err)
+			return
+		}
+	}
+
+	// reloc reports the free memory of the heap.
+	total, free := values[0], values[1]
+	ch <- prometheus.MustNewConstMetric(
+		c.gpuRelocTotal,
+		prometheus.GaugeValue, total,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.gpuRelocUsed,
+		prometheus.GaugeValue, total-free,
+	)
+}
+
+// parseTemp parses the output of vcgencmd measure_temp.
+func parseTemp(stdout []byte) (float64, error) {
+	// temp=55.3'C => 55.3
+	return parseValue(stdout)
+}
+
+// parseClock parses the output of vcgencmd measure_clock.
+func parseClock(stdout []byte) (float64, error) {
+	// frequency(1)=400000000 => 400000000
+	return parseValue(stdout)
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// splitList splits a comma separated list, omitting empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// readUintFromFile reads a single unsigned integer from the given file.
+func readUintFromFile(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// compileOptionalRegexp compiles the given pattern, anchored at both ends. It
+// returns nil for an empty pattern.
+func compileOptionalRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+}
+
+// runConcurrently runs the given functions concurrently, but no more than
+// --collector.max-concurrency at a time. It returns the first error.
+func runConcurrently(fns ...func() error) error {
+	limit := *maxConcurrency
+	if limit < 1 {
+		limit = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, limit)
+		errOnce  sync.Once
+		firstErr error
+	)
+	wg.Add(len(fns))
+	for _, fn := range fns {
+		sem <- struct{}{}
+		go func(fn func() error) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(); err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+		}(fn)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"golang.org/x/sys/unix"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	inputSubsystem = "input"
+
+	// Type of key events and the value of a key press in struct input_event.
+	evKey      = 1
+	keyPressed = 1
+)
+
+// inputEventSize is the size of struct input_event, a struct timeval followed
+// by the 16 bit type, the 16 bit code and the 32 bit value.
+var inputEventSize = int(unsafe.Sizeof(unix.Timeval{})) + 8
+
+var (
+	inputDevices = kingpin.Flag("collector.input.devices", "Regexp of the names of the input devices to count the key presses of.").Default(`^(pwr_button|gpio[_-]keys|.*[Bb]utton.*)$`).String()
+)
+
+// inputDevice is an opened event device of an input device.
+type inputDevice struct {
+	fd      int
+	name    string
+	presses float64
+}
+
+type inputCollector struct {
+	devicePattern *regexp.Regexp
+	events        *prometheus.Desc
+
+	mu sync.Mutex
+	// devices holds the opened event devices by their name, e.g. event0.
+	devices map[string]*inputDevice
+}
+
+func init() {
+	registerCollector("input", defaultDisabled, NewInputCollector)
+}
+
+// NewInputCollector returns a new Collector counting the key presses of input
+// devices like the power button.
+func NewInputCollector() (Collector, error) {
+	devicePattern, err := regexp.Compile(*inputDevices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input devices pattern: %s", err)
+	}
+	ic := &inputCollector{
+		devicePattern: devicePattern,
+		devices:       make(map[string]*inputDevice),
+		events: prometheus.NewDesc(
+			fqName("input", inputSubsystem, "events_total"),
+			"Number of key presses of the input device since the exporter started watching it.",
+			[]string{"device", "name"}, nil,
+		),
+	}
+	return ic, nil
+}
+
+// Update implements the Collector interface.
+func (c *inputCollector) Update(ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Get all the event devices from /sys/class/input/input*/event*.
+	dirs, err := filepath.Glob(sysFilePath("class/input/input[0-9]*/event[0-9]*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		event := filepath.Base(dir)
+		if _, ok := c.devices[event]; ok {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(dir), "name"))
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSpace(string(b))
+		if !c.devicePattern.MatchString(name) {
+			continue
+		}
+
+		// Events are only queued while the device is open, so it is kept
+		// open across scrapes.
+		path := rootfsFilePath(filepath.Join("/dev/input", event))
+		fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("couldn't open %s: %s", path, err)
+		}
+		log.Debugf("Watching input device %s (%s)", name, path)
+		c.devices[event] = &inputDevice{fd: fd, name: name}
+	}
+	if len(c.devices) == 0 {
+		log.Debugf("No input device matching %s found", c.devicePattern)
+		return nil
+	}
+
+	buf := make([]byte, 64*inputEventSize)
+	for event, device := range c.devices {
+		if err := device.read(buf); err != nil {
+			// The device is gone, e.g. unplugged. It is reopened once it
+			// reappears.
+			log.Debugf("Couldn't read input device %s: %s", device.name, err)
+			unix.Close(device.fd)
+			delete(c.devices, event)
+			continue
+		}
+
+		// Export the metric.
+		ch <- prometheus.MustNewConstMetric(
+			c.events,
+			prometheus.CounterValue, device.presses,
+			event, device.name,
+		)
+	}
+
+	return nil
+}
+
+// read reads all queued events of the device and counts the key presses.
+func (d *inputDevice) read(buf []byte) error {
+	for {
+		n, err := unix.Read(d.fd, buf)
+		if err == unix.EAGAIN {
+			return nil
+		} else if err != nil {
+			return err
+		} else if n == 0 {
+			return nil
+		}
+		tvSize := inputEventSize - 8
+		for off := 0; off+inputEventSize <= n; off += inputEventSize {
+			ev := buf[off : off+inputEventSize]
+			typ := binary.LittleEndian.Uint16(ev[tvSize:])
+			value := int32(binary.LittleEndian.Uint32(ev[tvSize+4:]))
+			if typ == evKey && value == keyPressed {
+				d.presses++
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const kernelLogSubsystem = "kernel_log"
+
+var (
+	kernelLogPatterns = kingpin.Flag("collector.kernellog.pattern", "Name and regexp of kernel log messages to count, as name=regexp. Can be repeated.").Default(
+		"undervoltage=Under-?voltage detected",
+		"sdcard=mmc[0-9]+: .*(error|timeout)",
+	).StringMap()
+	kernelLogLookback = kingpin.Flag("collector.kernellog.lookback", "How far back to read the kernel log on the first scrape.").Default("1h").Duration()
+	kernelLogMaxLevel = kingpin.Flag("collector.kernellog.max-level", "Maximum syslog level of messages to count (3=err, 4=warning).").Default("4").Int()
+)
+
+type kernelLogPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+type kernelLogCollector struct {
+	patterns []kernelLogPattern
+	lookback time.Duration
+	maxLevel int
+	events   *prometheus.Desc
+
+	mu     sync.Mutex
+	reader kmsgReader
+	// counts holds the number of matching records per pattern.
+	counts map[string]float64
+}
+
+func init() {
+	registerCollector("kernellog", defaultDisabled, NewKernelLogCollector)
+}
+
+// NewKernelLogCollector returns a new Collector counting kernel log messages
+// matching the configured patterns.
+func NewKernelLogCollector() (Collector, error) {
+	kc := &kernelLogCollector{
+		lookback: *kernelLogLookback,
+		maxLevel: *kernelLogMaxLevel,
+		counts:   make(map[string]float64),
+		events: prometheus.NewDesc(
+			fqName("kernellog", kernelLogSubsystem, "events_total"),
+			"Number of kernel log messages matching a pattern.",
+			[]string{"pattern"}, nil,
+		),
+	}
+	for name, pattern := range *kernelLogPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kernel log pattern %s: %s", name, err)
+		}
+		kc.patterns = append(kc.patterns, kernelLogPattern{name, re})
+		kc.counts[name] = 0
+	}
+	// Keep the order stable, since the map order is random.
+	sort.Slice(kc.patterns, func(i, j int) bool {
+		return kc.patterns[i].name < kc.patterns[j].name
+	})
+	return kc, nil
+}
+
+// Update implements the Collector interface.
+func (c *kernelLogCollector) Update(ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.readRecords(); err != nil {
+		return err
+	}
+
+	for _, p := range c.patterns {
+		ch <- prometheus.MustNewConstMetric(
+			c.events,
+			prometheus.CounterValue, c.counts[p.name],
+			p.name,
+		)
+	}
+
+	return nil
+}
+
+// readRecords reads all records from /dev/kmsg which haven't been read yet and
+// counts those matching a pattern. On the first call, only records within the
+// lookback are considered.
+func (c *kernelLogCollector) readRecords() error {
+	return c.reader.read(c.lookback, func(prio int, msg []byte) {
+		if prio&7 > c.maxLevel {
+			return
+		}
+		for _, p := range c.patterns {
+			if p.re.Match(msg) {
+				c.counts[p.name]++
+			}
+		}
+	})
+}
+
+// readUptime returns the time since boot from /proc/uptime.
+func readUptime() (time.Duration, error) {
+	b, err := ioutil.ReadFile(procFilePath("uptime"))
+	if err != nil {
+		return 0, err
+	}
+	// 12345.67 23456.78
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("invalid uptime: %q", b)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const kmsgPath = "/dev/kmsg"
+
+// kmsgReader reads the records of /dev/kmsg incrementally. lastSeq is the
+// sequence number of the last record read.
+type kmsgReader struct {
+	lastSeq uint64
+	started bool
+}
+
+// read calls fn with the priority and message of all records which haven't
+// been read yet. On the first call, only records within the lookback are
+// considered. A lookback of 0 considers all records in the buffer.
+func (r *kmsgReader) read(lookback time.Duration, fn func(prio int, msg []byte)) error {
+	uptime, err := readUptime()
+	if err != nil {
+		return err
+	}
+
+	// Open non-blocking, so reading stops at the end of the buffer instead of
+	// waiting for new records.
+	path := rootfsFilePath(kmsgPath)
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %s", path, err)
+	}
+	defer unix.Close(fd)
+
+	buf := make([]byte, 8192)
+	for {
+		n, err := unix.Read(fd, buf)
+		if err == unix.EAGAIN {
+			break
+		} else if err == unix.EPIPE {
+			// Records were overwritten while reading, continue with the
+			// next one available.
+			continue
+		} else if err != nil {
+			return fmt.Errorf("couldn't read %s: %s", path, err)
+		}
+
+		// 6,1234,5678901,-;message
+		idx := bytes.IndexByte(buf[:n], ';')
+		if idx == -1 {
+			continue
+		}
+		header := strings.Split(string(buf[:idx]), ",")
+		if len(header) < 3 {
+			continue
+		}
+		prio, err := strconv.Atoi(header[0])
+		if err != nil {
+			continue
+		}
+		seq, err := strconv.ParseUint(header[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		usec, err := strconv.ParseFloat(header[2], 64)
+		if err != nil {
+			continue
+		}
+
+		if r.started && seq <= r.lastSeq {
+			continue
+		}
+		r.lastSeq = seq
+		if !r.started && lookback > 0 && uptime-time.Duration(usec)*time.Microsecond > lookback {
+			continue
+		}
+
+		fn(prio, buf[idx+1:n])
+	}
+	r.started = true
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type loadavgCollector struct {
+	load []*prometheus.Desc
+}
+
+func init() {
+	registerCollector("loadavg", defaultEnabled, NewLoadavgCollector)
+}
+
+// NewLoadavgCollector returns a new Collector exposing the load averages from
+// /proc/loadavg.
+func NewLoadavgCollector() (Collector, error) {
+	lc := &loadavgCollector{}
+	for _, minutes := range []string{"1", "5", "15"} {
+		lc.load = append(lc.load, prometheus.NewDesc(
+			fqName("loadavg", "", "load"+minutes),
+			minutes+"m load average.",
+			nil, nil,
+		))
+	}
+	return lc, nil
+}
+
+// Update implements the Collector interface.
+func (c *loadavgCollector) Update(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(procFilePath("loadavg"))
+	if err != nil {
+		return err
+	}
+
+	// 0.15 0.10 0.05 1/123 4567
+	fields := strings.Fields(string(b))
+	if len(fields) < len(c.load) {
+		return fmt.Errorf("invalid loadavg: %q", b)
+	}
+	for i, desc := range c.load {
+		load, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.GaugeValue, load,
+		)
+	}
+
+	return nil
+}
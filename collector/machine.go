@@ -0,0 +1,113 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/unix"
+)
+
+const machineSubsystem = "machine"
+
+type machineCollector struct {
+	machineInfo *prometheus.Desc
+
+	// Architecture and model don't change while the system is running, so
+	// they are only read once.
+	arch  string
+	model string
+}
+
+func init() {
+	registerCollector("machine", defaultEnabled, NewMachineCollector)
+}
+
+// NewMachineCollector returns a new Collector exposing the architecture, the
+// number of online CPUs and the model of the machine.
+func NewMachineCollector() (Collector, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return nil, err
+	}
+
+	mc := &machineCollector{
+		machineInfo: prometheus.NewDesc(
+			fqName("machine", machineSubsystem, "info"),
+			"Architecture, number of online CPUs and model of the machine.",
+			[]string{"arch", "cores", "model"}, nil,
+		),
+		arch:  unix.ByteSliceToString(uname.Machine[:]),
+		model: readModel(),
+	}
+	return mc, nil
+}
+
+// readModel returns the model of the machine from the device tree. It is
+// NUL-terminated, e.g. "Raspberry Pi 4 Model B Rev 1.4\x00", and absent on
+// machines without a device tree.
+func readModel() string {
+	b, err := ioutil.ReadFile(procFilePath("device-tree/model"))
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimRight(b, "\x00\n"))
+}
+
+// Update implements the Collector interface.
+func (c *machineCollector) Update(ch chan<- prometheus.Metric) error {
+	// CPUs can be hotplugged, so the online CPUs are read on every scrape.
+	b, err := ioutil.ReadFile(sysFilePath("devices/system/cpu/online"))
+	if err != nil {
+		return err
+	}
+	cores, err := countCPUList(strings.TrimSpace(string(b)))
+	if err != nil {
+		return err
+	}
+
+	// Export the metric.
+	ch <- prometheus.MustNewConstMetric(
+		c.machineInfo,
+		prometheus.GaugeValue, 1,
+		c.arch, strconv.Itoa(cores), c.model,
+	)
+
+	return nil
+}
+
+// countCPUList returns the number of CPUs in a kernel CPU list like "0-3,5".
+func countCPUList(list string) (int, error) {
+	var n int
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid cpu list %q: %s", list, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid cpu list %q: %s", list, err)
+			}
+		}
+		n += last - first + 1
+	}
+	return n, nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const mdSubsystem = "md"
+
+var (
+	// [2/1] => total and active disks.
+	mdDisksRE = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+	// resync = 12.6% (123456/976630464) => synced and total blocks.
+	mdSyncRE = regexp.MustCompile(`(resync|recovery|check|reshape)\s*=\s*\S+%\s*\((\d+)/(\d+)\)`)
+)
+
+// mdArray is a software RAID array of /proc/mdstat.
+type mdArray struct {
+	device  string
+	active  int
+	failed  int
+	spare   int
+	synced  float64
+	hasSync bool
+}
+
+type mdadmCollector struct {
+	disks       *prometheus.Desc
+	syncedRatio *prometheus.Desc
+}
+
+func init() {
+	registerCollector("mdadm", defaultDisabled, NewMdadmCollector)
+}
+
+// NewMdadmCollector returns a new Collector exposing the state of the software
+// RAID arrays from /proc/mdstat.
+func NewMdadmCollector() (Collector, error) {
+	mc := &mdadmCollector{
+		disks: prometheus.NewDesc(
+			fqName("mdadm", mdSubsystem, "disks"),
+			"Number of disks of the software RAID array by state (active, failed or spare).",
+			[]string{"device", "state"}, nil,
+		),
+		syncedRatio: prometheus.NewDesc(
+			fqName("mdadm", mdSubsystem, "blocks_synced_ratio"),
+			"Ratio of the synced blocks of the software RAID array during a resync, recovery, check or reshape. 1 otherwise.",
+			[]string{"device"}, nil,
+		),
+	}
+	return mc, nil
+}
+
+// Update implements the Collector interface.
+func (c *mdadmCollector) Update(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(procFilePath("mdstat"))
+	if os.IsNotExist(err) {
+		// The md driver isn't loaded.
+		log.Debugf("No software RAID found: %s", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	arrays, err := parseMdstat(string(b))
+	if err != nil {
+		return err
+	}
+
+	// Export the metrics.
+	for _, a := range arrays {
+		for state, n := range map[string]int{"active": a.active, "failed": a.failed, "spare": a.spare} {
+			ch <- prometheus.MustNewConstMetric(
+				c.disks,
+				prometheus.GaugeValue, float64(n),
+				a.device, state,
+			)
+		}
+		synced := 1.0
+		if a.hasSync {
+			synced = a.synced
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.syncedRatio,
+			prometheus.GaugeValue, synced,
+			a.device,
+		)
+	}
+
+	return nil
+}
+
+// parseMdstat parses the arrays of /proc/mdstat.
+func parseMdstat(mdstat string) ([]mdArray, error) {
+	// md0 : active raid1 sdb1[1] sda1[0](F)
+	//       976630464 blocks super 1.2 [2/1] [U_]
+	//       [==>..................]  recovery = 12.6% (123456/976630464) ...
+	var arrays []mdArray
+	for _, line := range strings.Split(mdstat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.HasPrefix(fields[0], "md") && fields[1] == ":" {
+			a := mdArray{device: fields[0]}
+			for _, member := range fields[3:] {
+				switch {
+				case strings.HasSuffix(member, "(F)"):
+					a.failed++
+				case strings.HasSuffix(member, "(S)"):
+					a.spare++
+				}
+			}
+			arrays = append(arrays, a)
+			continue
+		}
+		if len(arrays) == 0 {
+			continue
+		}
+		a := &arrays[len(arrays)-1]
+
+		if m := mdDisksRE.FindStringSubmatch(line); m != nil && strings.Contains(line, "blocks") {
+			active, err := strconv.Atoi(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid mdstat line %q: %s", line, err)
+			}
+			a.active = active
+		}
+		if m := mdSyncRE.FindStringSubmatch(line); m != nil {
+			done, err := strconv.ParseFloat(m[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mdstat line %q: %s", line, err)
+			}
+			total, err := strconv.ParseFloat(m[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mdstat line %q: %s", line, err)
+			}
+			if total > 0 {
+				a.synced, a.hasSync = done/total, true
+			}
+		}
+	}
+	return arrays, nil
+}
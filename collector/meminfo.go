@@ -0,0 +1,114 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const cmaSubsystem = "cma"
+
+type meminfoCollector struct {
+	cmaTotal *prometheus.Desc
+	cmaFree  *prometheus.Desc
+}
+
+func init() {
+	registerCollector("meminfo", defaultEnabled, NewMeminfoCollector)
+}
+
+// NewMeminfoCollector returns a new Collector exposing Pi specific memory stats
+// from /proc/meminfo.
+func NewMeminfoCollector() (Collector, error) {
+	mc := &meminfoCollector{
+		cmaTotal: prometheus.NewDesc(
+			fqName("meminfo", cmaSubsystem, "total_bytes"),
+			"Size of the contiguous memory allocator (CMA) pool in bytes.",
+			nil, nil,
+		),
+		cmaFree: prometheus.NewDesc(
+			fqName("meminfo", cmaSubsystem, "free_bytes"),
+			"Free memory of the contiguous memory allocator (CMA) pool in bytes.",
+			nil, nil,
+		),
+	}
+	return mc, nil
+}
+
+// Update implements the Collector interface.
+func (c *meminfoCollector) Update(ch chan<- prometheus.Metric) error {
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return err
+	}
+
+	// The CMA lines are missing if the kernel is built without CMA.
+	total, ok := meminfo["CmaTotal"]
+	if !ok {
+		log.Debugln("No CMA found in meminfo")
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.cmaTotal,
+		prometheus.GaugeValue, total,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.cmaFree,
+		prometheus.GaugeValue, meminfo["CmaFree"],
+	)
+
+	return nil
+}
+
+// readMeminfo reads /proc/meminfo, see parseMeminfo.
+func readMeminfo() (map[string]float64, error) {
+	f, err := os.Open(procFilePath("meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
+
+// parseMeminfo parses the contents of /proc/meminfo into a map of keys to
+// values. Values in kB are converted to bytes.
+func parseMeminfo(r io.Reader) (map[string]float64, error) {
+	meminfo := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// CmaTotal:         262144 kB
+		// HugePages_Total:       0
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meminfo line %q: %s", scanner.Text(), err)
+		}
+		if len(fields) == 3 && fields[2] == "kB" {
+			v *= 1024
+		}
+		meminfo[strings.TrimSuffix(fields[0], ":")] = v
+	}
+	return meminfo, scanner.Err()
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const networkSubsystem = "network"
+
+var (
+	netdevIgnoredDevices = kingpin.Flag("collector.netdev.ignored-devices", "Regexp of network devices to ignore.").Default(`^(lo|docker\d+|veth.+|br-.+)$`).String()
+)
+
+type netdevCollector struct {
+	ignoredDevices *regexp.Regexp
+	linkSpeed      *prometheus.Desc
+	carrier        *prometheus.Desc
+	mtu            *prometheus.Desc
+	up             *prometheus.Desc
+}
+
+func init() {
+	registerCollector("netdev", defaultEnabled, NewNetdevCollector)
+}
+
+// NewNetdevCollector returns a new Collector exposing the link state and MTU of
+// the network devices from /sys/class/net.
+func NewNetdevCollector() (Collector, error) {
+	ignoredDevices, err := regexp.Compile(*netdevIgnoredDevices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignored network devices pattern: %s", err)
+	}
+	nc := &netdevCollector{
+		ignoredDevices: ignoredDevices,
+		linkSpeed: prometheus.NewDesc(
+			fqName("netdev", networkSubsystem, "link_speed_bits_per_second"),
+			"Negotiated link speed of the network device in bits per second.",
+			[]string{"device"}, nil,
+		),
+		carrier: prometheus.NewDesc(
+			fqName("netdev", networkSubsystem, "carrier"),
+			"Whether the network device has a carrier, e.g. a cable is plugged in.",
+			[]string{"device"}, nil,
+		),
+		mtu: prometheus.NewDesc(
+			fqName("netdev", networkSubsystem, "mtu"),
+			"MTU of the network device in bytes.",
+			[]string{"device"}, nil,
+		),
+		up: prometheus.NewDesc(
+			fqName("netdev", networkSubsystem, "up"),
+			"Whether the operational state of the network device is up.",
+			[]string{"device"}, nil,
+		),
+	}
+	return nc, nil
+}
+
+// Update implements the Collector interface.
+func (c *netdevCollector) Update(ch chan<- prometheus.Metric) error {
+	devices, err := filepath.Glob(sysFilePath("class/net/*"))
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range devices {
+		device := filepath.Base(dir)
+		if c.ignoredDevices.MatchString(device) {
+			continue
+		}
+
+		mtu, err := readUintFromFile(filepath.Join(dir, "mtu"))
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "operstate"))
+		if err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.mtu,
+			prometheus.GaugeValue, float64(mtu),
+			device,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.up,
+			prometheus.GaugeValue, boolToFloat(strings.TrimSpace(string(b)) == "up"),
+			device,
+		)
+
+		// Reading carrier and speed fails with EINVAL if the device is down,
+		// so both are skipped in that case.
+		carrier, err := readUintFromFile(filepath.Join(dir, "carrier"))
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.carrier,
+			prometheus.GaugeValue, float64(carrier),
+			device,
+		)
+
+		// The speed is in Mbit/s. It is -1 (or 4294967295 on older kernels)
+		// if it is unknown, e.g. for wireless devices or without a link.
+		b, err = ioutil.ReadFile(filepath.Join(dir, "speed"))
+		if err != nil {
+			continue
+		}
+		speed, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil || speed <= 0 || speed == 1<<32-1 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.linkSpeed,
+			prometheus.GaugeValue, float64(speed)*1e6,
+			device,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"path/filepath"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	procPath   = kingpin.Flag("path.procfs", "procfs mountpoint.").Default("/proc").String()
+	sysPath    = kingpin.Flag("path.sysfs", "sysfs mountpoint.").Default("/sys").String()
+	rootfsPath = kingpin.Flag("path.rootfs", "Root directory all files are read relative to, e.g. an extracted snapshot of /sys and /proc.").Default("/").String()
+)
+
+// rootfsFilePath returns the given absolute path below the root directory.
+func rootfsFilePath(name string) string {
+	return filepath.Join(*rootfsPath, name)
+}
+
+// procFilePath returns the path of the given file below the procfs mountpoint.
+func procFilePath(name string) string {
+	return rootfsFilePath(filepath.Join(*procPath, name))
+}
+
+// sysFilePath returns the path of the given file below the sysfs mountpoint.
+func sysFilePath(name string) string {
+	return rootfsFilePath(filepath.Join(*sysPath, name))
+}
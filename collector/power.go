@@ -0,0 +1,95 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	powerSubsystem = "power"
+	// Name of the hwmon device of the firmware voltage monitor.
+	rpiVoltHwmon = "rpi_volt"
+)
+
+type powerCollector struct {
+	undervoltage *prometheus.Desc
+}
+
+func init() {
+	registerCollector("power", defaultEnabled, NewPowerCollector)
+}
+
+// NewPowerCollector returns a new Collector exposing the power supply state
+// reported by the rpi_volt hwmon device.
+func NewPowerCollector() (Collector, error) {
+	pc := &powerCollector{
+		undervoltage: prometheus.NewDesc(
+			fqName("power", powerSubsystem, "undervoltage_detected"),
+			"Whether the firmware currently detects an under-voltage of the power supply.",
+			nil, nil,
+		),
+	}
+	return pc, nil
+}
+
+// Update implements the Collector interface.
+func (c *powerCollector) Update(ch chan<- prometheus.Metric) error {
+	dir, err := findHwmon(rpiVoltHwmon)
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		// Boards and kernels without the rpi_volt driver only report
+		// under-voltage via the throttle collector.
+		log.Debugf("No %s hwmon device found", rpiVoltHwmon)
+		return nil
+	}
+
+	// in0_lcrit_alarm is set while the voltage is below the critical limit.
+	alarm, err := readUintFromFile(filepath.Join(dir, "in0_lcrit_alarm"))
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.undervoltage,
+		prometheus.GaugeValue, boolToFloat(alarm != 0),
+	)
+
+	return nil
+}
+
+// findHwmon returns the sysfs directory of the hwmon device with the given
+// name or an empty string, if there is none.
+func findHwmon(name string) (string, error) {
+	dirs, err := filepath.Glob(sysFilePath("class/hwmon/hwmon*"))
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range dirs {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(b)) == name {
+			return dir, nil
+		}
+	}
+	return "", nil
+}
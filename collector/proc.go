@@ -0,0 +1,188 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	processSubsystem = "process"
+
+	// userHZ is the unit of the CPU times in /proc/<pid>/stat. It is 100 on
+	// all architectures supported by the Pi.
+	userHZ = 100
+
+	// maxProcessTop bounds --collector.proc.top to keep the cardinality low.
+	maxProcessTop = 50
+)
+
+// processStates maps the state field of /proc/<pid>/stat to a name.
+var processStates = map[byte]string{
+	'R': "running",
+	'S': "sleeping",
+	'D': "disk_sleep",
+	'Z': "zombie",
+	'T': "stopped",
+	't': "tracing_stop",
+	'X': "dead",
+	'I': "idle",
+}
+
+var (
+	processTop = kingpin.Flag("collector.proc.top", fmt.Sprintf("Number of process names with the highest CPU time to export (max %d).", maxProcessTop)).Default("10").Int()
+	processMax = kingpin.Flag("collector.proc.max-processes", "Maximum number of processes to scan per scrape. Further processes are ignored.").Default("4096").Int()
+)
+
+type procCollector struct {
+	top        int
+	max        int
+	cpuSeconds *prometheus.Desc
+	states     *prometheus.Desc
+}
+
+func init() {
+	registerCollector("proc", defaultDisabled, NewProcCollector)
+}
+
+// NewProcCollector returns a new Collector exposing the CPU time of the
+// processes using the most of it and the number of processes by state.
+func NewProcCollector() (Collector, error) {
+	if *processTop < 1 || *processTop > maxProcessTop {
+		return nil, fmt.Errorf("--collector.proc.top must be between 1 and %d", maxProcessTop)
+	}
+	if *processMax < 1 {
+		return nil, fmt.Errorf("--collector.proc.max-processes must be positive")
+	}
+	pc := &procCollector{
+		top: *processTop,
+		max: *processMax,
+		cpuSeconds: prometheus.NewDesc(
+			fqName("proc", processSubsystem, "cpu_seconds"),
+			"User and system CPU time of the running processes with the given name in seconds. It drops when a process exits, so it isn't a counter.",
+			[]string{"comm"}, nil,
+		),
+		states: prometheus.NewDesc(
+			fqName("proc", "processes", "state"),
+			"Number of processes in the given state.",
+			[]string{"state"}, nil,
+		),
+	}
+	return pc, nil
+}
+
+// Update implements the Collector interface.
+func (c *procCollector) Update(ch chan<- prometheus.Metric) error {
+	stats, err := filepath.Glob(procFilePath("[0-9]*/stat"))
+	if err != nil {
+		return err
+	}
+	if len(stats) > c.max {
+		log.Debugf("Scanning only %d of %d processes", c.max, len(stats))
+		stats = stats[:c.max]
+	}
+
+	// Processes are aggregated by name, so e.g. all vcgencmd children end up
+	// in a single series.
+	ticks := make(map[string]uint64)
+	states := make(map[string]float64)
+	for _, name := range processStates {
+		states[name] = 0
+	}
+	for _, stat := range stats {
+		comm, state, t, err := readProcessStat(stat)
+		if os.IsNotExist(err) {
+			// The process exited in the meantime.
+			continue
+		} else if err != nil {
+			return err
+		}
+		ticks[comm] += t
+		if name, ok := processStates[state]; ok {
+			states[name]++
+		}
+	}
+
+	comms := make([]string, 0, len(ticks))
+	for comm := range ticks {
+		comms = append(comms, comm)
+	}
+	sort.Slice(comms, func(i, j int) bool {
+		return ticks[comms[i]] > ticks[comms[j]]
+	})
+	if len(comms) > c.top {
+		comms = comms[:c.top]
+	}
+
+	// Export the metrics.
+	for _, comm := range comms {
+		ch <- prometheus.MustNewConstMetric(
+			c.cpuSeconds,
+			prometheus.GaugeValue,
+			float64(ticks[comm])/userHZ,
+			comm,
+		)
+	}
+	for state, n := range states {
+		ch <- prometheus.MustNewConstMetric(
+			c.states,
+			prometheus.GaugeValue, n,
+			state,
+		)
+	}
+
+	return nil
+}
+
+// readProcessStat reads the name, state and the user and system CPU time in
+// clock ticks from the given /proc/<pid>/stat file.
+func readProcessStat(path string) (string, byte, uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	// 1234 (vcgencmd) S 1 1234 1234 0 -1 4194560 ... utime stime ...
+	// The name may contain spaces and parentheses, so it ends at the last ")".
+	start, end := bytes.IndexByte(b, '('), bytes.LastIndexByte(b, ')')
+	if start == -1 || end < start {
+		return "", 0, 0, fmt.Errorf("invalid process stat %s", path)
+	}
+	comm := string(b[start+1 : end])
+	// The fields after the name start with the state (3rd field), utime and
+	// stime are the 14th and 15th.
+	fields := bytes.Fields(b[end+1:])
+	if len(fields) < 13 || len(fields[0]) != 1 {
+		return "", 0, 0, fmt.Errorf("invalid process stat %s", path)
+	}
+	utime, err := strconv.ParseUint(string(fields[11]), 10, 64)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	stime, err := strconv.ParseUint(string(fields[12]), 10, 64)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return comm, fields[0][0], utime + stime, nil
+}
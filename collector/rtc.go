@@ -0,0 +1,87 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	rtcSubsystem = "rtc"
+	rtcDevice    = "rtc0"
+)
+
+type rtcCollector struct {
+	present *prometheus.Desc
+	offset  *prometheus.Desc
+}
+
+func init() {
+	registerCollector("rtc", defaultDisabled, NewRTCCollector)
+}
+
+// NewRTCCollector returns a new Collector exposing the presence and offset of
+// the real-time clock.
+func NewRTCCollector() (Collector, error) {
+	rc := &rtcCollector{
+		present: prometheus.NewDesc(
+			fqName("rtc", rtcSubsystem, "present"),
+			"Whether a real-time clock is present.",
+			nil, nil,
+		),
+		offset: prometheus.NewDesc(
+			fqName("rtc", rtcSubsystem, "offset_seconds"),
+			"Time of the real-time clock minus the system time in seconds. The real-time clock has a resolution of one second.",
+			nil, nil,
+		),
+	}
+	return rc, nil
+}
+
+// Update implements the Collector interface.
+func (c *rtcCollector) Update(ch chan<- prometheus.Metric) error {
+	// Most Pis don't have a real-time clock, unless a HAT adds one.
+	dir := sysFilePath(filepath.Join("class/rtc", rtcDevice))
+	_, err := os.Stat(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	present := err == nil
+	ch <- prometheus.MustNewConstMetric(
+		c.present,
+		prometheus.GaugeValue, boolToFloat(present),
+	)
+	if !present {
+		return nil
+	}
+
+	// Reading the clock fails if it isn't set or its battery is empty.
+	sinceEpoch, err := readUintFromFile(filepath.Join(dir, "since_epoch"))
+	if err != nil {
+		log.Debugf("Couldn't read real-time clock: %s", err)
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.offset,
+		prometheus.GaugeValue,
+		float64(sinceEpoch)-float64(time.Now().UnixNano())/1e9,
+	)
+
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	sdcardSubsystem = "sdcard"
+	sdcardDevice    = "mmcblk0"
+	sdcardHost      = "mmc0"
+)
+
+var (
+	sdcardBus = kingpin.Flag("collector.sdcard.bus", "Export the negotiated bus clock and timing mode of the SD card. Requires debugfs, which is usually only readable by root.").Bool()
+)
+
+type sdcardCollector struct {
+	sdcardInfo     *prometheus.Desc
+	sdcardLifetime *prometheus.Desc
+	sdcardBusSpeed *prometheus.Desc
+	sdcardBusMode  *prometheus.Desc
+	bus            bool
+
+	// The card identity doesn't change while the system is running, so it is
+	// only read once.
+	mu   sync.Mutex
+	info []string
+}
+
+func init() {
+	registerCollector("sdcard", defaultEnabled, NewSDCardCollector)
+}
+
+// NewSDCardCollector returns a new Collector exposing the identity of the SD
+// card.
+func NewSDCardCollector() (Collector, error) {
+	sc := &sdcardCollector{
+		bus: *sdcardBus,
+		sdcardInfo: prometheus.NewDesc(
+			fqName("sdcard", sdcardSubsystem, "info"),
+			"Identity of the SD card as reported by the card.",
+			[]string{"name", "manufacturer_id", "date", "cid"}, nil,
+		),
+		sdcardLifetime: prometheus.NewDesc(
+			fqName("sdcard", sdcardSubsystem, "lifetime_percent"),
+			"Lower bound of the estimated used lifetime of the card's memory in percent, in steps of 10%. 100 if exceeded.",
+			[]string{"type"}, nil,
+		),
+		sdcardBusSpeed: prometheus.NewDesc(
+			fqName("sdcard", sdcardSubsystem, "bus_speed_hertz"),
+			"Clock of the SD card bus in hertz (Hz).",
+			nil, nil,
+		),
+		sdcardBusMode: prometheus.NewDesc(
+			fqName("sdcard", sdcardSubsystem, "bus_mode"),
+			"Timing mode negotiated with the SD card, e.g. sd high-speed or mmc HS200.",
+			[]string{"mode"}, nil,
+		),
+	}
+	return sc, nil
+}
+
+// Update implements the Collector interface.
+func (c *sdcardCollector) Update(ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.info == nil {
+		dir := sysFilePath(filepath.Join("block", sdcardDevice, "device"))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			// Not booted from an SD card.
+			log.Debugf("No SD card found at %s", dir)
+			return nil
+		}
+		info, err := readSDCardInfo(dir)
+		if err != nil {
+			return err
+		}
+		c.info = info
+	}
+
+	// Export the metric.
+	ch <- prometheus.MustNewConstMetric(
+		c.sdcardInfo,
+		prometheus.GaugeValue, 1,
+		c.info...,
+	)
+
+	if err := c.updateLifetime(ch); err != nil {
+		return err
+	}
+	if c.bus {
+		return c.updateBus(ch)
+	}
+	return nil
+}
+
+// updateBus exports the bus clock and timing mode of the SD card host from
+// debugfs.
+func (c *sdcardCollector) updateBus(ch chan<- prometheus.Metric) error {
+	path := sysFilePath(filepath.Join("kernel/debug", sdcardHost, "ios"))
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Debugf("No SD card bus information at %s, is debugfs mounted?", path)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// actual clock:	50000000 Hz
+	// timing spec:	2 (sd high-speed)
+	ios := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		ios[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	// Older kernels only report the requested clock.
+	clock, ok := ios["actual clock"]
+	if !ok {
+		clock = ios["clock"]
+	}
+	if fields := strings.Fields(clock); len(fields) > 0 {
+		hz, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid bus clock: %q", clock)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.sdcardBusSpeed,
+			prometheus.GaugeValue, hz,
+		)
+	}
+
+	timing := ios["timing spec"]
+	if start, end := strings.IndexByte(timing, '('), strings.LastIndexByte(timing, ')'); start != -1 && end > start {
+		ch <- prometheus.MustNewConstMetric(
+			c.sdcardBusMode,
+			prometheus.GaugeValue, 1,
+			timing[start+1:end],
+		)
+	}
+
+	return nil
+}
+
+// updateLifetime exports the lifetime estimate of eMMC and some industrial
+// cards. Other cards don't have the attribute.
+func (c *sdcardCollector) updateLifetime(ch chan<- prometheus.Metric) error {
+	b, err := ioutil.ReadFile(sysFilePath(filepath.Join("block", sdcardDevice, "device/life_time")))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The estimates for the type A and B memory of the card. 0x01 to 0x0a
+	// mean 0-10% to 90-100% used, 0x0b exceeded. 0x00 is undefined.
+	// 0x01 0x02
+	for i, value := range strings.Fields(string(b)) {
+		if i > 1 {
+			break
+		}
+		estimate, err := strconv.ParseUint(value, 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid life_time: %q", b)
+		}
+		if estimate == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.sdcardLifetime,
+			prometheus.GaugeValue, float64(estimate-1)*10,
+			[]string{"A", "B"}[i],
+		)
+	}
+
+	return nil
+}
+
+// readSDCardInfo reads the name, manufacturer id, manufacturing date and CID of
+// the SD card from the given sysfs device directory.
+func readSDCardInfo(dir string) ([]string, error) {
+	var values []string
+	for _, file := range []string{"name", "manfid", "date", "cid"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, strings.TrimSpace(string(b)))
+	}
+
+	// 0x000003 => 0x03
+	manfid, err := strconv.ParseUint(values[1], 0, 32)
+	if err != nil {
+		return nil, err
+	}
+	values[1] = fmt.Sprintf("0x%02x", manfid)
+
+	return values, nil
+}
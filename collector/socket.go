@@ -0,0 +1,118 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const sockstatSubsystem = "sockstat"
+
+type socketCollector struct {
+	tcpInuse *prometheus.Desc
+	tcpTw    *prometheus.Desc
+	udpInuse *prometheus.Desc
+}
+
+func init() {
+	registerCollector("socket", defaultEnabled, NewSocketCollector)
+}
+
+// NewSocketCollector returns a new Collector exposing socket stats from
+// /proc/net/sockstat.
+func NewSocketCollector() (Collector, error) {
+	sc := &socketCollector{
+		tcpInuse: prometheus.NewDesc(
+			fqName("socket", sockstatSubsystem, "tcp_inuse"),
+			"Number of TCP sockets in use.",
+			nil, nil,
+		),
+		tcpTw: prometheus.NewDesc(
+			fqName("socket", sockstatSubsystem, "tcp_tw"),
+			"Number of TCP sockets in TIME_WAIT state.",
+			nil, nil,
+		),
+		udpInuse: prometheus.NewDesc(
+			fqName("socket", sockstatSubsystem, "udp_inuse"),
+			"Number of UDP sockets in use.",
+			nil, nil,
+		),
+	}
+	return sc, nil
+}
+
+// Update implements the Collector interface.
+func (c *socketCollector) Update(ch chan<- prometheus.Metric) error {
+	f, err := os.Open(procFilePath("net/sockstat"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stats, err := parseSockstat(f)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range []struct {
+		desc          *prometheus.Desc
+		protocol, key string
+	}{
+		{c.tcpInuse, "TCP", "inuse"},
+		{c.tcpTw, "TCP", "tw"},
+		{c.udpInuse, "UDP", "inuse"},
+	} {
+		v, ok := stats[m.protocol][m.key]
+		if !ok {
+			return fmt.Errorf("missing %s %s in sockstat", m.protocol, m.key)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			m.desc,
+			prometheus.GaugeValue, v,
+		)
+	}
+
+	return nil
+}
+
+// parseSockstat parses the contents of /proc/net/sockstat into a map of
+// protocol to key/value pairs.
+func parseSockstat(r io.Reader) (map[string]map[string]float64, error) {
+	stats := make(map[string]map[string]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// TCP: inuse 5 orphan 0 tw 0 alloc 7 mem 1
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || len(fields)%2 != 1 {
+			return nil, fmt.Errorf("invalid sockstat line: %q", scanner.Text())
+		}
+		protocol := strings.TrimSuffix(fields[0], ":")
+		stats[protocol] = make(map[string]float64)
+		for i := 1; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil, err
+			}
+			stats[protocol][fields[i]] = v
+		}
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"github.com/prometheus/client_golang/prometheus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const systemdSubsystem = "systemd"
+
+// The active states a unit can be in.
+var systemdUnitStates = []string{"active", "activating", "deactivating", "inactive", "failed"}
+
+var (
+	systemdUnitInclude = kingpin.Flag("collector.systemd.unit-include", "Regexp of systemd units to export the state of.").Default(`.+\.service`).String()
+)
+
+type systemdCollector struct {
+	unitInclude *regexp.Regexp
+	unitState   *prometheus.Desc
+}
+
+func init() {
+	registerCollector("systemd", defaultDisabled, NewSystemdCollector)
+}
+
+// NewSystemdCollector returns a new Collector exposing the state of systemd
+// units.
+func NewSystemdCollector() (Collector, error) {
+	unitInclude, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", *systemdUnitInclude))
+	if err != nil {
+		return nil, fmt.Errorf("invalid systemd unit include pattern: %s", err)
+	}
+	sc := &systemdCollector{
+		unitInclude: unitInclude,
+		unitState: prometheus.NewDesc(
+			fqName("systemd", systemdSubsystem, "unit_state"),
+			"Systemd unit active state.",
+			[]string{"name", "state"}, nil,
+		),
+	}
+	return sc, nil
+}
+
+// Update implements the Collector interface.
+func (c *systemdCollector) Update(ch chan<- prometheus.Metric) error {
+	conn, err := dbus.New()
+	if err != nil {
+		return fmt.Errorf("couldn't connect to systemd: %s", err)
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnits()
+	if err != nil {
+		return fmt.Errorf("couldn't list systemd units: %s", err)
+	}
+
+	for _, unit := range units {
+		if !c.unitInclude.MatchString(unit.Name) {
+			continue
+		}
+		for _, state := range systemdUnitStates {
+			ch <- prometheus.MustNewConstMetric(
+				c.unitState,
+				prometheus.GaugeValue,
+				boolToFloat(unit.ActiveState == state),
+				unit.Name, state,
+			)
+		}
+	}
+
+	return nil
+}
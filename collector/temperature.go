@@ -0,0 +1,106 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// Bounds of plausible temperatures. Readings outside of them are considered
+// garbage returned by the sensor.
+const (
+	minTempCelsius = -40
+	maxTempCelsius = 150
+)
+
+// newTemperatureDesc returns the desc of the family consolidating the
+// temperatures of all sensors, for the given collector reading them.
+func newTemperatureDesc(collector string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		fqName(collector, "", "temperature_celsius"),
+		"Temperature of a sensor in degrees celsius (°C).",
+		[]string{"sensor"}, nil,
+	)
+}
+
+// readThermalZoneTemp reads the temperature in millidegrees celsius from the
+// given thermal zone file and returns it in degrees celsius.
+func readThermalZoneTemp(path string) (float64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	temp, err := strconv.ParseFloat(string(bytes.TrimSpace(b)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return temp / 1000, nil
+}
+
+// findThermalZone returns the directory of the thermal zone with the given
+// type. The numbering of the zones varies across kernels, so it is searched on
+// every call. If the type is empty or doesn't match, thermal_zone0 is used.
+func findThermalZone(zoneType string) (string, error) {
+	zone0 := sysFilePath("class/thermal/thermal_zone0")
+	if zoneType == "" {
+		return zone0, nil
+	}
+	zones, err := filepath.Glob(sysFilePath("class/thermal/thermal_zone*"))
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range zones {
+		b, err := ioutil.ReadFile(filepath.Join(zone, "type"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(b)) == zoneType {
+			return zone, nil
+		}
+	}
+	log.Debugf("No thermal zone of type %s found, using thermal_zone0", zoneType)
+	return zone0, nil
+}
+
+// SoCTemperature returns the temperature of the SoC in degrees celsius, read
+// from the thermal zone used by the cpu collector. Like the cpu collector, it
+// rejects implausible temperatures.
+func SoCTemperature() (float64, error) {
+	zone, err := findThermalZone(*cpuThermalZoneType)
+	if err != nil {
+		return 0, err
+	}
+	temp, err := readThermalZoneTemp(filepath.Join(zone, "temp"))
+	if err != nil {
+		return 0, err
+	}
+	if !validTemp(temp) {
+		return 0, fmt.Errorf("implausible temperature of %g°C", temp)
+	}
+	return temp, nil
+}
+
+// validTemp reports whether the given temperature in degrees celsius is within
+// plausible bounds.
+func validTemp(temp float64) bool {
+	return temp >= minTempCelsius && temp <= maxTempCelsius
+}
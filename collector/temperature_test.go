@@ -0,0 +1,43 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidTemp(t *testing.T) {
+	tests := []struct {
+		temp  float64
+		valid bool
+	}{
+		{minTempCelsius - 0.001, false},
+		{minTempCelsius, true},
+		{0, true},
+		{48.5, true},
+		{maxTempCelsius, true},
+		{maxTempCelsius + 0.001, false},
+		{-273.15, false},
+		{2147483.647, false},
+		{math.NaN(), false},
+		{math.Inf(1), false},
+		{math.Inf(-1), false},
+	}
+	for _, tt := range tests {
+		if valid := validTemp(tt.temp); valid != tt.valid {
+			t.Errorf("validTemp(%g): expected %t, got %t", tt.temp, tt.valid, valid)
+		}
+	}
+}
@@ -223,6 +223,8 @@ fileLoop:
 		mtimes[f.Name()] = f.ModTime()
 
 		for _, mf := range parsedFamilies {
+			name := metricName("textfile", mf.GetName())
+			mf.Name = &name
 			convertMetricFamily(mf, ch)
 		}
 	}
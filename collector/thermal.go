@@ -0,0 +1,356 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	thermalSubsystem       = "thermal"
+	coolingDeviceSubsystem = "cooling_device"
+	fanSubsystem           = "fan"
+
+	// Type of the cooling device and name of the hwmon device of the fan
+	// driven by the pwm-fan driver, e.g. the Pi 5 active cooler.
+	fanCoolingDeviceType = "pwm-fan"
+	fanHwmon             = "pwmfan"
+	// Maximum value of the hwmon pwm attribute.
+	maxPWM = 255
+
+	// Name of the hwmon device of the Pi 5 RP1 I/O controller's ADC, which
+	// provides the RP1 temperature on kernels without an RP1 thermal zone.
+	rp1Hwmon = "rp1_adc"
+)
+
+// thermalZoneSensors maps thermal zone types to the name of the sensor they
+// belong to. Other zones are labeled with their type.
+var thermalZoneSensors = map[string]string{
+	"rp1_adc":     "rp1",
+	"rp1-thermal": "rp1",
+}
+
+type thermalCollector struct {
+	zoneTempCelsius    *prometheus.Desc
+	temperature        *prometheus.Desc
+	tripPointCelsius   *prometheus.Desc
+	coolingDeviceCur   *prometheus.Desc
+	coolingDeviceMax   *prometheus.Desc
+	coolingDeviceTrans *prometheus.Desc
+	coolingDeviceTime  *prometheus.Desc
+	fanLevel           *prometheus.Desc
+	fanDutyRatio       *prometheus.Desc
+}
+
+func init() {
+	registerCollector("thermal", defaultEnabled, NewThermalCollector)
+}
+
+// NewThermalCollector returns a new Collector exposing the thermal zone trip
+// points and the state of the cooling devices.
+func NewThermalCollector() (Collector, error) {
+	tc := &thermalCollector{
+		temperature: newTemperatureDesc("thermal"),
+		zoneTempCelsius: prometheus.NewDesc(
+			fqName("thermal", thermalSubsystem, "zone_temperature_celsius"),
+			"Temperature of a thermal zone in degrees celsius (°C).",
+			[]string{"zone", "type", "sensor"}, nil,
+		),
+		tripPointCelsius: prometheus.NewDesc(
+			fqName("thermal", thermalSubsystem, "trip_point_celsius"),
+			"Temperature of a thermal zone trip point in degrees celsius (°C).",
+			[]string{"zone", "point", "type"}, nil,
+		),
+		coolingDeviceCur: prometheus.NewDesc(
+			fqName("thermal", coolingDeviceSubsystem, "cur_state"),
+			"Current throttle state of the cooling device.",
+			[]string{"device", "type"}, nil,
+		),
+		coolingDeviceMax: prometheus.NewDesc(
+			fqName("thermal", coolingDeviceSubsystem, "max_state"),
+			"Maximum throttle state of the cooling device.",
+			[]string{"device", "type"}, nil,
+		),
+		coolingDeviceTrans: prometheus.NewDesc(
+			fqName("thermal", thermalSubsystem, "cooling_transitions_total"),
+			"Number of throttle state transitions of the cooling device.",
+			[]string{"device", "type"}, nil,
+		),
+		coolingDeviceTime: prometheus.NewDesc(
+			fqName("thermal", thermalSubsystem, "cooling_time_in_state_seconds_total"),
+			"Total time the cooling device spent in a throttle state in seconds.",
+			[]string{"device", "type", "state"}, nil,
+		),
+		fanLevel: prometheus.NewDesc(
+			fqName("thermal", fanSubsystem, "level"),
+			"Current cooling level of the PWM fan.",
+			[]string{"device"}, nil,
+		),
+		fanDutyRatio: prometheus.NewDesc(
+			fqName("thermal", fanSubsystem, "duty_ratio"),
+			"Current duty cycle of the PWM fan (0-1).",
+			[]string{"device"}, nil,
+		),
+	}
+	return tc, nil
+}
+
+// Update implements the Collector interface.
+func (c *thermalCollector) Update(ch chan<- prometheus.Metric) error {
+	// Get all the zones from /sys/class/thermal/thermal_zone*.
+	zones, err := filepath.Glob(sysFilePath("class/thermal/thermal_zone[0-9]*"))
+	if err != nil {
+		return err
+	}
+
+	hasRP1 := false
+	for _, zone := range zones {
+		zoneID := strings.TrimPrefix(filepath.Base(zone), "thermal_zone")
+
+		b, err := ioutil.ReadFile(filepath.Join(zone, "type"))
+		if err != nil {
+			return err
+		}
+		zoneType := strings.TrimSpace(string(b))
+		sensor, ok := thermalZoneSensors[zoneType]
+		if !ok {
+			sensor = zoneType
+		}
+
+		// Disabled zones fail to read with EINVAL or ENODATA, which must not
+		// fail the whole collector.
+		temp, err := readThermalZoneTemp(filepath.Join(zone, "temp"))
+		if err != nil {
+			log.Debugf("Skipping temperature of thermal zone %s (%s): %s", zoneID, zoneType, err)
+		} else if !validTemp(temp) {
+			log.Warnf("Skipping implausible temperature of thermal zone %s (%s) of %g°C", zoneID, zoneType, temp)
+		} else {
+			ch <- prometheus.MustNewConstMetric(
+				c.zoneTempCelsius,
+				prometheus.GaugeValue, temp,
+				zoneID, zoneType, sensor,
+			)
+			// The cpu and gpu collectors export the SoC temperature to the
+			// consolidated family.
+			if sensor == "rp1" && !hasRP1 {
+				ch <- prometheus.MustNewConstMetric(
+					c.temperature,
+					prometheus.GaugeValue, temp,
+					sensor,
+				)
+				hasRP1 = true
+			}
+		}
+
+		// Pair each trip_point_*_temp with its trip_point_*_type.
+		points, err := filepath.Glob(filepath.Join(zone, "trip_point_[0-9]*_temp"))
+		if err != nil {
+			return err
+		}
+		for _, point := range points {
+			pointID := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(point), "trip_point_"), "_temp")
+
+			temp, err := readThermalZoneTemp(point)
+			if err != nil {
+				return err
+			}
+			// Disabled trip points report THERMAL_TEMP_INVALID (-274°C), so
+			// out of bounds ones are only logged at debug level.
+			if !validTemp(temp) {
+				log.Debugf("Skipping implausible trip point %s of thermal zone %s of %g°C", pointID, zoneID, temp)
+				continue
+			}
+			b, err := ioutil.ReadFile(strings.TrimSuffix(point, "_temp") + "_type")
+			if err != nil {
+				return err
+			}
+
+			// Export the metric.
+			ch <- prometheus.MustNewConstMetric(
+				c.tripPointCelsius,
+				prometheus.GaugeValue, temp,
+				zoneID, pointID, strings.TrimSpace(string(b)),
+			)
+		}
+	}
+
+	if !hasRP1 {
+		if err := c.updateRP1(ch); err != nil {
+			return err
+		}
+	}
+
+	return c.updateCoolingDevices(ch)
+}
+
+// updateRP1 exports the temperature of the RP1 I/O controller from its hwmon
+// device, if present. The zone label is empty, since it isn't a thermal zone.
+func (c *thermalCollector) updateRP1(ch chan<- prometheus.Metric) error {
+	dir, err := findHwmon(rp1Hwmon)
+	if err != nil || dir == "" {
+		return err
+	}
+	temp, err := readThermalZoneTemp(filepath.Join(dir, "temp1_input"))
+	if err != nil {
+		return err
+	}
+	if !validTemp(temp) {
+		log.Warnf("Skipping implausible RP1 temperature of %g°C", temp)
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.zoneTempCelsius,
+		prometheus.GaugeValue, temp,
+		"", rp1Hwmon, "rp1",
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.temperature,
+		prometheus.GaugeValue, temp,
+		"rp1",
+	)
+	return nil
+}
+
+func (c *thermalCollector) updateCoolingDevices(ch chan<- prometheus.Metric) error {
+	// Get all the cooling devices from /sys/class/thermal/cooling_device*.
+	devices, err := filepath.Glob(sysFilePath("class/thermal/cooling_device[0-9]*"))
+	if err != nil {
+		return err
+	}
+
+	for _, device := range devices {
+		deviceID := strings.TrimPrefix(filepath.Base(device), "cooling_device")
+
+		b, err := ioutil.ReadFile(filepath.Join(device, "type"))
+		if err != nil {
+			return err
+		}
+		deviceType := strings.TrimSpace(string(b))
+
+		curState, err := readUintFromFile(filepath.Join(device, "cur_state"))
+		if err != nil {
+			return err
+		}
+		maxState, err := readUintFromFile(filepath.Join(device, "max_state"))
+		if err != nil {
+			return err
+		}
+
+		// Export the metrics.
+		ch <- prometheus.MustNewConstMetric(
+			c.coolingDeviceCur,
+			prometheus.GaugeValue, float64(curState),
+			deviceID, deviceType,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.coolingDeviceMax,
+			prometheus.GaugeValue, float64(maxState),
+			deviceID, deviceType,
+		)
+
+		if err := c.updateCoolingDeviceStats(ch, device, deviceID, deviceType); err != nil {
+			return err
+		}
+
+		if deviceType == fanCoolingDeviceType {
+			if err := c.updateFan(ch, deviceID, curState, maxState); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// updateCoolingDeviceStats exports the transitions and the time in each state
+// of the given cooling device. They are only available if the kernel is built
+// with CONFIG_THERMAL_STATISTICS.
+func (c *thermalCollector) updateCoolingDeviceStats(ch chan<- prometheus.Metric, device, deviceID, deviceType string) error {
+	trans, err := readUintFromFile(filepath.Join(device, "stats/total_trans"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.coolingDeviceTrans,
+		prometheus.CounterValue, float64(trans),
+		deviceID, deviceType,
+	)
+
+	b, err := ioutil.ReadFile(filepath.Join(device, "stats/time_in_state_ms"))
+	if err != nil {
+		return err
+	}
+	// One line per state with the time in milliseconds.
+	// state0	123456
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		ms, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.coolingDeviceTime,
+			prometheus.CounterValue, float64(ms)/1000,
+			deviceID, deviceType, strings.TrimPrefix(fields[0], "state"),
+		)
+	}
+	return nil
+}
+
+// updateFan exports the level and duty cycle of the PWM fan with the given
+// cooling device id.
+func (c *thermalCollector) updateFan(ch chan<- prometheus.Metric, deviceID string, level, maxLevel uint64) error {
+	ch <- prometheus.MustNewConstMetric(
+		c.fanLevel,
+		prometheus.GaugeValue, float64(level),
+		deviceID,
+	)
+
+	// The levels map to the PWM values configured in the device tree, so the
+	// actual duty cycle is read from the hwmon device. Without it, the duty
+	// cycle is approximated by the level.
+	var duty float64
+	dir, err := findHwmon(fanHwmon)
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		pwm, err := readUintFromFile(filepath.Join(dir, "pwm1"))
+		if err != nil {
+			return err
+		}
+		duty = float64(pwm) / maxPWM
+	} else if maxLevel > 0 {
+		duty = float64(level) / float64(maxLevel)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.fanDutyRatio,
+		prometheus.GaugeValue, duty,
+		deviceID,
+	)
+
+	return nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	throttleSubsystem = "throttle"
+	// get_throttled reports the state of the whole SoC.
+	throttleComponent = "soc"
+)
+
+var (
+	throttleSinceStart = kingpin.Flag("collector.throttle.since-start", "Also export which throttle events occurred since the exporter started.").Default("false").Bool()
+	throttleStateSet   = kingpin.Flag("collector.throttle.state-set", "Also export the active throttle conditions as state set rpi_throttle_state{state=\"...\"}, encoded as gauges.").Default("false").Bool()
+)
+
+// The since-start tracking and the active time are shared by all instances of
+// the collector, which are also created for filtered requests, so they are
+// tracked since the exporter started.
+var (
+	throttleBaselineOnce sync.Once
+	throttleMu           sync.Mutex
+	// throttleBaseline holds the sticky bits set when the exporter started,
+	// throttleSeen the flags observed since.
+	throttleBaseline    uint64
+	throttleHasBaseline bool
+	throttleSeen        uint64
+	// throttleActiveSeconds holds the estimated time each flag was active,
+	// based on the time of the last scrape.
+	throttleActiveSeconds = make([]float64, len(throttleFlags))
+	throttleLastScrape    time.Time
+)
+
+// throttleFlag describes a condition reported by vcgencmd get_throttled. The
+// firmware sets the bit at the given offset while the condition is active and
+// the bit at offset+16 once it has occurred since boot. The latter can't be
+// cleared.
+//
+// The conditions apply to the whole SoC, the firmware doesn't report them for
+// the CPU and GPU separately. They are exported with component="soc" so
+// per-component flags can be added, should the firmware ever provide them.
+type throttleFlag struct {
+	name   string
+	help   string
+	offset uint
+}
+
+func (f throttleFlag) activeMask() uint64   { return 1 << f.offset }
+func (f throttleFlag) occurredMask() uint64 { return 1 << (f.offset + 16) }
+
+var throttleFlags = []throttleFlag{
+	{"undervoltage", "under-voltage", 0},
+	{"frequency_capped", "arm frequency capping", 1},
+	{"throttled", "throttling", 2},
+	{"soft_temp_limit", "soft temperature limit", 3},
+}
+
+type throttleCollector struct {
+	vcgencmd   string
+	sinceStart bool
+	stateSet   bool
+	state      *prometheus.Desc
+	active     []*prometheus.Desc
+	occurred   []*prometheus.Desc
+	started    []*prometheus.Desc
+	seconds    []*prometheus.Desc
+}
+
+func init() {
+	registerCollector("throttle", defaultEnabled, NewThrottleCollector)
+}
+
+// NewThrottleCollector returns a new Collector exposing the throttle state
+// reported by vcgencmd get_throttled.
+func NewThrottleCollector() (Collector, error) {
+	tc := &throttleCollector{
+		vcgencmd:   vcgencmdPath(),
+		sinceStart: *throttleSinceStart,
+		stateSet:   *throttleStateSet,
+		state: prometheus.NewDesc(
+			fqName("throttle", throttleSubsystem, "state"),
+			"Whether a throttle condition is currently active, as state set.",
+			[]string{"component", "state"}, nil,
+		),
+	}
+	for _, f := range throttleFlags {
+		tc.active = append(tc.active, prometheus.NewDesc(
+			fqName("throttle", throttleSubsystem, f.name),
+			"Whether "+f.help+" is currently active.",
+			[]string{"component"}, nil,
+		))
+		tc.occurred = append(tc.occurred, prometheus.NewDesc(
+			fqName("throttle", throttleSubsystem, f.name+"_occurred"),
+			"Whether "+f.help+" has occurred since boot.",
+			[]string{"component"}, nil,
+		))
+		tc.started = append(tc.started, prometheus.NewDesc(
+			fqName("throttle", throttleSubsystem, f.name+"_since_start"),
+			"Whether "+f.help+" has occurred since the exporter started.",
+			[]string{"component"}, nil,
+		))
+		tc.seconds = append(tc.seconds, prometheus.NewDesc(
+			fqName("throttle", throttleSubsystem, f.name+"_seconds_total"),
+			"Estimated seconds "+f.help+" was active, counting the time since the previous scrape if it is active when scraped.",
+			[]string{"component"}, nil,
+		))
+	}
+
+	// Record the sticky bits at startup, so events since then can be told
+	// apart from earlier ones.
+	if tc.sinceStart {
+		throttleBaselineOnce.Do(func() {
+			mask, err := tc.getThrottled()
+			if err != nil {
+				log.Warnf("Couldn't read throttle baseline, deferring to first scrape: %s", err)
+				return
+			}
+			throttleMu.Lock()
+			throttleBaseline, throttleHasBaseline = mask, true
+			throttleMu.Unlock()
+		})
+	}
+	return tc, nil
+}
+
+// Update implements the Collector interface.
+func (c *throttleCollector) Update(ch chan<- prometheus.Metric) error {
+	mask, err := c.getThrottled()
+	if err != nil {
+		return vcgencmdUnavailable(err)
+	}
+
+	for i, f := range throttleFlags {
+		ch <- prometheus.MustNewConstMetric(
+			c.active[i],
+			prometheus.GaugeValue,
+			boolToFloat(mask&f.activeMask() != 0),
+			throttleComponent,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.occurred[i],
+			prometheus.GaugeValue,
+			boolToFloat(mask&f.occurredMask() != 0),
+			throttleComponent,
+		)
+	}
+
+	// A state set has a series per state, only the active ones are 1.
+	if c.stateSet {
+		for _, f := range throttleFlags {
+			ch <- prometheus.MustNewConstMetric(
+				c.state,
+				prometheus.GaugeValue,
+				boolToFloat(mask&f.activeMask() != 0),
+				throttleComponent, f.name,
+			)
+		}
+	}
+
+	throttleMu.Lock()
+	now := time.Now()
+	if !throttleLastScrape.IsZero() {
+		elapsed := now.Sub(throttleLastScrape).Seconds()
+		for i, f := range throttleFlags {
+			if mask&f.activeMask() != 0 {
+				throttleActiveSeconds[i] += elapsed
+			}
+		}
+	}
+	throttleLastScrape = now
+	activeSeconds := append([]float64(nil), throttleActiveSeconds...)
+	throttleMu.Unlock()
+
+	for i := range throttleFlags {
+		ch <- prometheus.MustNewConstMetric(
+			c.seconds[i],
+			prometheus.CounterValue,
+			activeSeconds[i],
+			throttleComponent,
+		)
+	}
+
+	if !c.sinceStart {
+		return nil
+	}
+
+	throttleMu.Lock()
+	if !throttleHasBaseline {
+		throttleBaseline, throttleHasBaseline = mask, true
+	}
+	// A sticky bit that wasn't set at startup must have been set since. For
+	// bits which already were, only the active bits observed while scraping
+	// tell about new events.
+	for _, f := range throttleFlags {
+		if mask&f.activeMask() != 0 ||
+			(mask&f.occurredMask() != 0 && throttleBaseline&f.occurredMask() == 0) {
+			throttleSeen |= f.occurredMask()
+		}
+	}
+	seen := throttleSeen
+	throttleMu.Unlock()
+
+	for i, f := range throttleFlags {
+		ch <- prometheus.MustNewConstMetric(
+			c.started[i],
+			prometheus.GaugeValue,
+			boolToFloat(seen&f.occurredMask() != 0),
+			throttleComponent,
+		)
+	}
+
+	return nil
+}
+
+// getThrottled executes vcgencmd get_throttled and returns the bitmask.
+func (c *throttleCollector) getThrottled() (uint64, error) {
+	stdout, err := vcgencmdOutput(c.vcgencmd, "get_throttled")
+	if err != nil {
+		return 0, err
+	}
+
+	// throttled=0x50005 => 0x50005
+	maskStr := strings.TrimSpace(string(stdout))
+	if idx := strings.IndexByte(maskStr, '='); idx != -1 {
+		maskStr = maskStr[idx+1:]
+	}
+	return strconv.ParseUint(maskStr, 0, 32)
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const undervoltageSubsystem = "undervoltage"
+
+// Messages logged by the firmware driver when the under-voltage state changes.
+var (
+	undervoltageDetected   = []byte("Under-voltage detected!")
+	undervoltageNormalised = []byte("Voltage normalised")
+)
+
+type undervoltageCollector struct {
+	events *prometheus.Desc
+	active *prometheus.Desc
+
+	mu     sync.Mutex
+	reader kmsgReader
+	// count is the number of under-voltage events logged, undervoltage
+	// whether the last logged state is under-voltage.
+	count        float64
+	undervoltage bool
+}
+
+func init() {
+	registerCollector("undervoltage", defaultDisabled, NewUndervoltageCollector)
+}
+
+// NewUndervoltageCollector returns a new Collector exposing the under-voltage
+// events logged by the kernel.
+func NewUndervoltageCollector() (Collector, error) {
+	uc := &undervoltageCollector{
+		events: prometheus.NewDesc(
+			fqName("undervoltage", undervoltageSubsystem, "kernel_events_total"),
+			"Number of under-voltage events logged by the kernel.",
+			nil, nil,
+		),
+		active: prometheus.NewDesc(
+			fqName("undervoltage", undervoltageSubsystem, "kernel_active"),
+			"Whether the last under-voltage state logged by the kernel is under-voltage.",
+			nil, nil,
+		),
+	}
+	return uc, nil
+}
+
+// Update implements the Collector interface.
+func (c *undervoltageCollector) Update(ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Read the whole buffer on the first scrape, so the state is known.
+	err := c.reader.read(0, func(_ int, msg []byte) {
+		switch {
+		case bytes.Contains(msg, undervoltageDetected):
+			c.count++
+			c.undervoltage = true
+		case bytes.Contains(msg, undervoltageNormalised):
+			c.undervoltage = false
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Export the metrics.
+	ch <- prometheus.MustNewConstMetric(
+		c.events,
+		prometheus.CounterValue, c.count,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.active,
+		prometheus.GaugeValue, boolToFloat(c.undervoltage),
+	)
+
+	return nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Known locations of vcgencmd, tried if the configured one isn't usable.
+var vcgencmdLocations = []string{
+	// RaspiOS 64bit
+	"/usr/bin/vcgencmd",
+	// RaspiOS 32bit
+	"/opt/vc/bin/vcgencmd",
+}
+
+var (
+	vcgencmdHistogram = kingpin.Flag("collector.vcgencmd.histogram", "Export a histogram of vcgencmd execution times by subcommand.").Bool()
+
+	vcgencmdOnce     sync.Once
+	vcgencmdResolved string
+)
+
+// Error strings printed by vcgencmd when it can't talk to the firmware, e.g.
+// because the mailbox is busy.
+var vcgencmdMailboxErrors = [][]byte{
+	[]byte("VCHI initialization failed"),
+	[]byte("mailbox"),
+}
+
+var vcgencmdErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "vcgencmd",
+		Name:      "errors_total",
+		Help:      "rpi_exporter: Number of failed vcgencmd executions by kind.",
+	},
+	[]string{"kind"},
+)
+
+var vcgencmdDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "vcgencmd",
+		Name:      "duration_seconds",
+		Help:      "rpi_exporter: Duration of vcgencmd executions by subcommand.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 8),
+	},
+	[]string{"subcommand"},
+)
+
+func init() {
+	// Initialize the well known kinds so they are exported before the first
+	// error occurs.
+	vcgencmdErrors.WithLabelValues("mailbox")
+	vcgencmdErrors.WithLabelValues("exec")
+}
+
+// vcgencmdPath returns the path of the vcgencmd binary to use. It verifies that
+// the configured binary is executable and otherwise uses the first executable
+// of vcgencmdLocations. The path is only resolved once.
+func vcgencmdPath() string {
+	vcgencmdOnce.Do(func() {
+		vcgencmdResolved = *vcgencmd
+		err := checkExecutable(vcgencmdResolved)
+		if err == nil {
+			return
+		}
+		for _, location := range vcgencmdLocations {
+			if location == *vcgencmd || checkExecutable(location) != nil {
+				continue
+			}
+			log.Warnf("Configured vcgencmd is not usable (%s), using %s instead", err, location)
+			vcgencmdResolved = location
+			return
+		}
+		log.Warnf("Configured vcgencmd is not usable, collectors depending on it will fail: %s", err)
+	})
+	return vcgencmdResolved
+}
+
+// VcgencmdPath returns the path of the vcgencmd binary used by the collectors.
+func VcgencmdPath() string {
+	return vcgencmdPath()
+}
+
+// checkExecutable returns an error if the given path isn't an executable file.
+func checkExecutable(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return &os.PathError{Op: "exec", Path: path, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+// vcgencmdOutput executes the given vcgencmd binary with the given arguments
+// and returns its standard output. Failures are counted by kind.
+func vcgencmdOutput(path string, args ...string) ([]byte, error) {
+	return runVcgencmd(exec.Command(path, args...), args)
+}
+
+// vcgencmdSudoOutput is like vcgencmdOutput, but executes vcgencmd via sudo.
+// sudo runs non-interactively and fails if it would prompt for a password.
+func vcgencmdSudoOutput(path string, args ...string) ([]byte, error) {
+	stdout, err := runVcgencmd(exec.Command("sudo", append([]string{"-n", path}, args...)...), args)
+	if exitErr, ok := err.(*exec.ExitError); ok && bytes.HasPrefix(exitErr.Stderr, []byte("sudo:")) {
+		return nil, fmt.Errorf("couldn't execute vcgencmd via sudo: %s", bytes.TrimSpace(exitErr.Stderr))
+	}
+	return stdout, err
+}
+
+func runVcgencmd(cmd *exec.Cmd, args []string) ([]byte, error) {
+	begin := time.Now()
+	stdout, err := cmd.Output()
+	if *vcgencmdHistogram && len(args) > 0 {
+		vcgencmdDuration.WithLabelValues(args[0]).Observe(time.Since(begin).Seconds())
+	}
+	if err != nil {
+		var stderr []byte
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = exitErr.Stderr
+		}
+		if isMailboxError(stdout) || isMailboxError(stderr) {
+			vcgencmdErrors.WithLabelValues("mailbox").Inc()
+		} else {
+			vcgencmdErrors.WithLabelValues("exec").Inc()
+		}
+		return nil, err
+	}
+	return stdout, nil
+}
+
+// vcgencmdUnavailable wraps the given error of a vcgencmd execution in a
+// non-fatal CollectorError, if vcgencmd isn't installed.
+func vcgencmdUnavailable(err error) error {
+	if vcgencmdMissing(err) {
+		return errUnavailable(err)
+	}
+	return err
+}
+
+// vcgencmdMissing reports whether the given error of a vcgencmd execution is
+// caused by vcgencmd not being installed.
+func vcgencmdMissing(err error) bool {
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr) && os.IsNotExist(pathErr)
+}
+
+func isMailboxError(b []byte) bool {
+	for _, msg := range vcgencmdMailboxErrors {
+		if bytes.Contains(b, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConfig parses the output of vcgencmd get_config into a map of keys to
+// values.
+func parseConfig(stdout []byte) map[string]string {
+	// arm_freq=1500
+	// core_freq=500
+	config := make(map[string]string)
+	for _, line := range strings.Split(string(stdout), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		config[kv[0]] = kv[1]
+	}
+	return config
+}
+
+// parseValue parses the number following the "=" in the output of vcgencmd.
+// It tolerates surrounding whitespace, a comma as decimal separator and
+// trailing units.
+func parseValue(stdout []byte) (float64, error) {
+	// temp=55,3'C => 55.3
+	str := string(stdout)
+	if idx := strings.IndexByte(str, '='); idx != -1 {
+		str = str[idx+1:]
+	}
+	str = strings.Replace(strings.TrimSpace(str), ",", ".", 1)
+	end := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if end != -1 {
+		str = str[:end]
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %s", bytes.TrimSpace(stdout), err)
+	}
+	return value, nil
+}
+
+// parseMem parses the output of vcgencmd get_mem and returns the amount in
+// bytes.
+func parseMem(stdout []byte) (float64, error) {
+	// reloc_total=200M => 200 * 1024 * 1024
+	memStr := strings.TrimSpace(string(stdout))
+	if idx := strings.IndexByte(memStr, '='); idx != -1 {
+		memStr = memStr[idx+1:]
+	}
+	memStr = strings.TrimSuffix(strings.ToUpper(memStr), "B")
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(memStr, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(memStr, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(memStr, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		memStr = memStr[:len(memStr)-1]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(memStr), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory value %q: %s", stdout, err)
+	}
+	return value * multiplier, nil
+}
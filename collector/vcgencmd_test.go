@@ -0,0 +1,56 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import "testing"
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func([]byte) (float64, error)
+		stdout string
+		value  float64
+		err    bool
+	}{
+		{"temp", parseTemp, "temp=55.3'C\n", 55.3, false},
+		{"temp comma", parseTemp, "temp=55,3'C\n", 55.3, false},
+		{"temp padded", parseTemp, "  temp= 55.3 'C \r\n", 55.3, false},
+		{"temp negative", parseTemp, "temp=-4,5'C", -4.5, false},
+		{"temp without key", parseTemp, "55.3'C", 55.3, false},
+		{"clock", parseClock, "frequency(1)=400000000\n", 400000000, false},
+		{"clock padded", parseClock, "\tfrequency(45)=  500000000  \n", 500000000, false},
+		{"volts", parseValue, "volt=1.2000V\n", 1.2, false},
+		{"empty", parseTemp, "", 0, true},
+		{"missing value", parseTemp, "temp='C\n", 0, true},
+		{"mailbox error", parseClock, "VCHI initialization failed\n", 0, true},
+		{"garbage", parseTemp, "temp=1.2.3'C", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.parse([]byte(tt.stdout))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error for %q, got %g", tt.stdout, value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %s", tt.stdout, err)
+			}
+			if value != tt.value {
+				t.Errorf("expected %g for %q, got %g", tt.value, tt.stdout, value)
+			}
+		})
+	}
+}
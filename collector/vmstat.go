@@ -0,0 +1,93 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const swapSubsystem = "swap"
+
+type vmstatCollector struct {
+	pageSize float64
+	swapIn   *prometheus.Desc
+	swapOut  *prometheus.Desc
+}
+
+func init() {
+	registerCollector("vmstat", defaultEnabled, NewVmstatCollector)
+}
+
+// NewVmstatCollector returns a new Collector exposing swap activity from
+// /proc/vmstat.
+func NewVmstatCollector() (Collector, error) {
+	vc := &vmstatCollector{
+		pageSize: float64(os.Getpagesize()),
+		swapIn: prometheus.NewDesc(
+			fqName("vmstat", swapSubsystem, "in_bytes_total"),
+			"Total bytes swapped in from disk.",
+			nil, nil,
+		),
+		swapOut: prometheus.NewDesc(
+			fqName("vmstat", swapSubsystem, "out_bytes_total"),
+			"Total bytes swapped out to disk.",
+			nil, nil,
+		),
+	}
+	return vc, nil
+}
+
+// Update implements the Collector interface.
+func (c *vmstatCollector) Update(ch chan<- prometheus.Metric) error {
+	f, err := os.Open(procFilePath("vmstat"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The swap activity is counted in pages.
+	// pswpin 123
+	// pswpout 456
+	descs := map[string]*prometheus.Desc{
+		"pswpin":  c.swapIn,
+		"pswpout": c.swapOut,
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		desc, ok := descs[fields[0]]
+		if !ok {
+			continue
+		}
+		pages, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value in vmstat: %s", fields[0], err)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue, pages*c.pageSize,
+		)
+	}
+
+	return scanner.Err()
+}
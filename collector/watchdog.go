@@ -0,0 +1,105 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	watchdogSubsystem = "watchdog"
+	watchdogDevice    = "watchdog0"
+)
+
+type watchdogCollector struct {
+	present *prometheus.Desc
+	active  *prometheus.Desc
+	timeout *prometheus.Desc
+}
+
+func init() {
+	registerCollector("watchdog", defaultDisabled, NewWatchdogCollector)
+}
+
+// NewWatchdogCollector returns a new Collector exposing the state of the
+// hardware watchdog.
+func NewWatchdogCollector() (Collector, error) {
+	wc := &watchdogCollector{
+		present: prometheus.NewDesc(
+			fqName("watchdog", watchdogSubsystem, "present"),
+			"Whether a hardware watchdog is present.",
+			nil, nil,
+		),
+		active: prometheus.NewDesc(
+			fqName("watchdog", watchdogSubsystem, "active"),
+			"Whether the hardware watchdog is armed.",
+			nil, nil,
+		),
+		timeout: prometheus.NewDesc(
+			fqName("watchdog", watchdogSubsystem, "timeout_seconds"),
+			"Timeout of the hardware watchdog in seconds.",
+			nil, nil,
+		),
+	}
+	return wc, nil
+}
+
+// Update implements the Collector interface.
+func (c *watchdogCollector) Update(ch chan<- prometheus.Metric) error {
+	dir := sysFilePath(filepath.Join("class/watchdog", watchdogDevice))
+	_, err := os.Stat(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	present := err == nil
+	ch <- prometheus.MustNewConstMetric(
+		c.present,
+		prometheus.GaugeValue, boolToFloat(present),
+	)
+	if !present {
+		return nil
+	}
+
+	// The attributes are only available if the kernel is built with
+	// CONFIG_WATCHDOG_SYSFS.
+	timeout, err := readUintFromFile(filepath.Join(dir, "timeout"))
+	if os.IsNotExist(err) {
+		log.Debugf("No watchdog attributes found in %s", dir)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.timeout,
+		prometheus.GaugeValue, float64(timeout),
+	)
+
+	// active or inactive
+	state, err := ioutil.ReadFile(filepath.Join(dir, "state"))
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.active,
+		prometheus.GaugeValue, boolToFloat(strings.TrimSpace(string(state)) == "active"),
+	)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/lukasmalkmus/rpi_exporter/collector"
+)
+
+// config is the effective configuration of the exporter.
+type config struct {
+	// Flags holds the values of all flags, including defaults.
+	Flags      map[string]string `json:"flags"`
+	Collectors []string          `json:"collectors"`
+	Vcgencmd   string            `json:"vcgencmd"`
+}
+
+// newConfigHandler returns a handler serving the effective configuration
+// resolved from the parsed flags of the given application as JSON.
+func newConfigHandler(app *kingpin.Application) (http.Handler, error) {
+	c := config{
+		Flags:      make(map[string]string),
+		Collectors: collector.EnabledCollectors(),
+		Vcgencmd:   collector.VcgencmdPath(),
+	}
+	for _, f := range app.Model().Flags {
+		if f.Name == "help" || f.Name == "version" {
+			continue
+		}
+		c.Flags[f.Name] = redactURL(f.Value.String())
+	}
+
+	// The flags don't change at runtime, so the response is built once.
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}), nil
+}
+
+// redactURL replaces the userinfo of the given value, if it is an url with
+// credentials like --remote-write.url. Other values are returned unchanged.
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	u.User = url.User("xxxxx")
+	return u.String()
+}
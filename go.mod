@@ -3,8 +3,12 @@ module github.com/lukasmalkmus/rpi_exporter
 go 1.13
 
 require (
+	github.com/coreos/go-systemd/v22 v22.3.2
+	github.com/golang/snappy v0.0.4
 	github.com/prometheus/client_golang v1.11.1
 	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.26.0
+	golang.org/x/sys v0.0.0-20210603081109-ebe580a85c40
+	google.golang.org/protobuf v1.26.0-rc.1
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 )
@@ -0,0 +1,112 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+)
+
+// newJSONHandler returns a handler serving the metrics of the given gatherer
+// as a JSON object for consumers without a Prometheus parser. Metrics without
+// labels map their name to the value, labeled metrics are nested by label
+// name and value in alphabetical order of the label names:
+//
+//	{"rpi_gpu_frequency_hertz": {"component": {"core": 500000000}}}
+func newJSONHandler(g prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mfs, err := g.Gather()
+		if err != nil {
+			log.Errorln("Couldn't gather metrics:", err)
+			http.Error(w, fmt.Sprintf("Couldn't gather metrics: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		metrics := make(map[string]interface{})
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				setJSONValue(metrics, mf.GetName(), m.Label, jsonValue(mf.GetType(), m))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(metrics); err != nil {
+			log.Errorln("Couldn't encode metrics:", err)
+		}
+	})
+}
+
+// setJSONValue sets the given value in obj under the given key, nested by the
+// names and values of the given labels.
+func setJSONValue(obj map[string]interface{}, key string, labels []*dto.LabelPair, value interface{}) {
+	labels = append([]*dto.LabelPair(nil), labels...)
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].GetName() < labels[j].GetName()
+	})
+
+	// rpi_gpu_frequency_hertz{component="core"} =>
+	// [rpi_gpu_frequency_hertz component core]
+	path := []string{key}
+	for _, l := range labels {
+		path = append(path, l.GetName(), l.GetValue())
+	}
+	for _, k := range path[:len(path)-1] {
+		nested, ok := obj[k].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			obj[k] = nested
+		}
+		obj = nested
+	}
+	obj[path[len(path)-1]] = value
+}
+
+// jsonValue returns the value of the given metric. Histograms and summaries are
+// represented by their count and sum.
+func jsonValue(t dto.MetricType, m *dto.Metric) interface{} {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return jsonFloat(m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		return jsonFloat(m.GetGauge().GetValue())
+	case dto.MetricType_UNTYPED:
+		return jsonFloat(m.GetUntyped().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		return map[string]interface{}{
+			"count": m.GetHistogram().GetSampleCount(),
+			"sum":   jsonFloat(m.GetHistogram().GetSampleSum()),
+		}
+	case dto.MetricType_SUMMARY:
+		return map[string]interface{}{
+			"count": m.GetSummary().GetSampleCount(),
+			"sum":   jsonFloat(m.GetSummary().GetSampleSum()),
+		}
+	}
+	return nil
+}
+
+// jsonFloat returns the given value, or nil if JSON can't represent it.
+func jsonFloat(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/log"
+)
+
+// Port of the remote exporter, if the target doesn't specify one.
+const probeDefaultPort = "9243"
+
+// Names of the metrics of the probe itself. Families of the target with the
+// same names are renamed.
+const (
+	probeSuccessName  = "rpi_exporter_probe_success"
+	probeDurationName = "rpi_exporter_probe_duration_seconds"
+)
+
+// probeHandler serves the metrics of the rpi_exporter running on the target
+// given by the target query parameter, similar to the blackbox_exporter. The
+// metrics are labeled with the target. Only the configured targets can be
+// probed, so the exporter can't be abused to send requests to arbitrary
+// hosts.
+type probeHandler struct {
+	client *http.Client
+	// targets holds the metrics urls of the allowed targets.
+	targets map[string]*url.URL
+}
+
+func newProbeHandler(timeout time.Duration, targets []string) (*probeHandler, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets configured")
+	}
+	h := &probeHandler{
+		client:  &http.Client{Timeout: timeout},
+		targets: make(map[string]*url.URL),
+	}
+	for _, target := range targets {
+		u, err := probeURL(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %q: %s", target, err)
+		}
+		h.targets[u.String()] = u
+	}
+	return h, nil
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	targetURL, err := probeURL(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid target: %s", err), http.StatusBadRequest)
+		return
+	}
+	if h.targets[targetURL.String()] == nil {
+		http.Error(w, fmt.Sprintf("Target %s is not allowed", targetURL.Host), http.StatusForbidden)
+		return
+	}
+
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: probeSuccessName,
+		Help: "Whether the metrics of the target could be fetched.",
+	})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: probeDurationName,
+		Help: "Duration of fetching the metrics of the target.",
+	})
+
+	// Use a fresh registry per probe, so the metrics of different targets
+	// don't mix.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(probeSuccess, probeDuration)
+
+	begin := time.Now()
+	mfs, err := h.fetch(targetURL)
+	probeDuration.Set(time.Since(begin).Seconds())
+	if err != nil {
+		log.Errorf("Probe of %s failed: %s", target, err)
+	} else {
+		probeSuccess.Set(1)
+	}
+
+	g := prometheus.Gatherers{reg, prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return mfs, nil
+	})}
+	promhttp.HandlerFor(g, promhttp.HandlerOpts{
+		ErrorLog:      log.NewErrorLogger(),
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	}).ServeHTTP(w, r)
+}
+
+// fetch fetches the metrics from the given url and labels them with the
+// target.
+func (h *probeHandler) fetch(targetURL *url.URL) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest(http.MethodGet, targetURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Only the text format can be parsed.
+	req.Header.Set("Accept", `text/plain;version=0.0.4`)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rename the families colliding with the metrics of the probe.
+	names := map[string]bool{probeSuccessName: true, probeDurationName: true}
+	for name := range families {
+		names[name] = true
+	}
+
+	mfs := make([]*dto.MetricFamily, 0, len(families))
+	for _, mf := range families {
+		if name := mf.GetName(); name == probeSuccessName || name == probeDurationName {
+			for names[name] {
+				name = "exported_" + name
+			}
+			names[name] = true
+			mf.Name = stringPtr(name)
+		}
+		for _, m := range mf.Metric {
+			// Keep a target label of the target itself, like Prometheus
+			// does with conflicting labels.
+			for _, l := range m.Label {
+				if l.GetName() == "target" {
+					l.Name = stringPtr("exported_target")
+				}
+			}
+			m.Label = append(m.Label, &dto.LabelPair{
+				Name:  stringPtr("target"),
+				Value: stringPtr(targetURL.Host),
+			})
+			sort.Slice(m.Label, func(i, j int) bool {
+				return m.Label[i].GetName() < m.Label[j].GetName()
+			})
+		}
+		mfs = append(mfs, mf)
+	}
+	return mfs, nil
+}
+
+// probeURL returns the metrics url of the given target, which is either a
+// host with an optional port or an url without path. The path is always
+// /metrics.
+func probeURL(target string) (*url.URL, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("only a host with an optional port is allowed")
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), probeDefaultPort)
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Path = "/metrics"
+	return u, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/log"
+)
+
+// newPusher creates a new pusher for the given Pushgateway which pushes the
+// metrics of the given gatherer. If instance is empty, the hostname is used.
+func newPusher(url, job, instance string, g prometheus.Gatherer) (*push.Pusher, error) {
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		instance = hostname
+	}
+	return push.New(url, job).Grouping("instance", instance).Gatherer(g), nil
+}
+
+// runPusher pushes metrics at the given interval until done is closed. Errors
+// are only logged.
+func runPusher(p *push.Pusher, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Push(); err != nil {
+			log.Errorln("Couldn't push metrics to Pushgateway:", err)
+		} else {
+			log.Debugln("Pushed metrics to Pushgateway")
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriter sends the metrics of a gatherer to a Prometheus remote-write
+// endpoint.
+type remoteWriter struct {
+	url    string
+	client *http.Client
+	g      prometheus.Gatherer
+	// labels are added to all series, sorted by name.
+	labels []*dto.LabelPair
+}
+
+// newRemoteWriter creates a new remoteWriter for the given endpoint, which
+// writes the metrics of the given gatherer. If instance is empty, the hostname
+// is used.
+func newRemoteWriter(url, job, instance string, timeout time.Duration, g prometheus.Gatherer) (*remoteWriter, error) {
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		instance = hostname
+	}
+	return &remoteWriter{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		g:      g,
+		labels: []*dto.LabelPair{
+			{Name: stringPtr("instance"), Value: stringPtr(instance)},
+			{Name: stringPtr("job"), Value: stringPtr(job)},
+		},
+	}, nil
+}
+
+// write gathers the metrics and sends them to the endpoint.
+func (rw *remoteWriter) write() error {
+	mfs, err := rw.g.Gather()
+	if err != nil {
+		// Send what was gathered anyways, like a scrape would.
+		log.Warnln("Error gathering metrics for remote-write:", err)
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(mfs, rw.labels, time.Now()))
+	req, err := http.NewRequest(http.MethodPost, rw.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "rpi_exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		line, _ := bufio.NewReader(io.LimitReader(resp.Body, 512)).ReadString('\n')
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, line)
+	}
+	return nil
+}
+
+// runRemoteWriter writes metrics at the given interval until done is closed.
+// Errors are only logged.
+func runRemoteWriter(rw *remoteWriter, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := rw.write(); err != nil {
+			log.Errorln("Couldn't remote-write metrics:", err)
+		} else {
+			log.Debugln("Remote-wrote metrics")
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// encodeWriteRequest encodes the given metric families as a remote-write
+// WriteRequest protobuf message. Summaries and histograms are split into
+// their series like in the text format.
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(mfs []*dto.MetricFamily, extraLabels []*dto.LabelPair, now time.Time) []byte {
+	var buf []byte
+	appendSeries := func(name string, m *dto.Metric, value float64, labels ...*dto.LabelPair) {
+		ts := now.UnixNano() / int64(time.Millisecond)
+		if m.TimestampMs != nil {
+			ts = m.GetTimestampMs()
+		}
+
+		all := []*dto.LabelPair{{Name: stringPtr("__name__"), Value: stringPtr(name)}}
+		all = append(all, m.Label...)
+		all = append(all, labels...)
+		// Labels of the metric take precedence over the extra labels.
+		for _, l := range extraLabels {
+			if !hasLabel(all, l.GetName()) {
+				all = append(all, l)
+			}
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].GetName() < all[j].GetName() })
+
+		var series []byte
+		for _, l := range all {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.GetName())
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.GetValue())
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(ts))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, sample)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, series)
+	}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				appendSeries(name, m, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				appendSeries(name, m, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				appendSeries(name, m, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					appendSeries(name, m, q.GetValue(), labelPair("quantile", q.GetQuantile()))
+				}
+				appendSeries(name+"_sum", m, s.GetSampleSum())
+				appendSeries(name+"_count", m, float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.Bucket {
+					appendSeries(name+"_bucket", m, float64(b.GetCumulativeCount()), labelPair("le", b.GetUpperBound()))
+				}
+				if n := len(h.Bucket); n == 0 || !math.IsInf(h.Bucket[n-1].GetUpperBound(), 1) {
+					appendSeries(name+"_bucket", m, float64(h.GetSampleCount()), labelPair("le", math.Inf(1)))
+				}
+				appendSeries(name+"_sum", m, h.GetSampleSum())
+				appendSeries(name+"_count", m, float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return buf
+}
+
+func labelPair(name string, value float64) *dto.LabelPair {
+	return &dto.LabelPair{Name: stringPtr(name), Value: stringPtr(formatFloat(value))}
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func hasLabel(labels []*dto.LabelPair, name string) bool {
+	for _, l := range labels {
+		if l.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
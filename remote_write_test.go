@@ -0,0 +1,209 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// field is a decoded protobuf field. Only the types used by the remote-write
+// messages are supported.
+type field struct {
+	num   protowire.Number
+	bytes []byte
+	value uint64
+}
+
+// decodeFields decodes the fields of the given protobuf message.
+func decodeFields(t *testing.T, b []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("Invalid tag: %s", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		default:
+			t.Fatalf("Unexpected wire type %d of field %d", typ, num)
+		}
+		if n < 0 {
+			t.Fatalf("Invalid field %d: %s", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// sample is a decoded remote-write sample.
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest decodes the given WriteRequest into a map of series, in
+// the form of the text format, to their sample. It fails if the labels of a
+// series aren't sorted or contain duplicates.
+func decodeWriteRequest(t *testing.T, b []byte) map[string]sample {
+	t.Helper()
+
+	series := make(map[string]sample)
+	for _, ts := range decodeFields(t, b) {
+		if ts.num != 1 {
+			t.Fatalf("Unexpected field %d of WriteRequest", ts.num)
+		}
+
+		var (
+			name   string
+			labels []string
+			s      sample
+			last   string
+		)
+		for _, f := range decodeFields(t, ts.bytes) {
+			switch f.num {
+			case 1:
+				var l [2]string
+				for _, lf := range decodeFields(t, f.bytes) {
+					l[lf.num-1] = string(lf.bytes)
+				}
+				if l[0] <= last {
+					t.Errorf("Label %q follows %q, expected sorted and unique labels", l[0], last)
+				}
+				last = l[0]
+				if l[0] == "__name__" {
+					name = l[1]
+				} else {
+					labels = append(labels, fmt.Sprintf("%s=%q", l[0], l[1]))
+				}
+			case 2:
+				for _, sf := range decodeFields(t, f.bytes) {
+					switch sf.num {
+					case 1:
+						s.value = math.Float64frombits(sf.value)
+					case 2:
+						s.timestamp = int64(sf.value)
+					}
+				}
+			default:
+				t.Fatalf("Unexpected field %d of TimeSeries", f.num)
+			}
+		}
+		series[name+"{"+strings.Join(labels, ",")+"}"] = s
+	}
+	return series
+}
+
+func TestRemoteWrite(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge."})
+	gauge.Set(42.5)
+	// The job label of a metric takes precedence over the configured one.
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "Test counter."}, []string{"job"})
+	counter.WithLabelValues("custom").Add(3)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_histogram", Help: "Test histogram.", Buckets: []float64{1, 2}})
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "test_summary", Help: "Test summary.", Objectives: map[float64]float64{0.5: 0.05}})
+	for _, v := range []float64{0.5, 1.5, 3} {
+		histogram.Observe(v)
+		summary.Observe(v)
+	}
+	reg.MustRegister(gauge, counter, histogram, summary)
+
+	var (
+		body   []byte
+		header http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Couldn't read request body: %s", err)
+		}
+		if body, err = snappy.Decode(nil, b); err != nil {
+			t.Errorf("Couldn't decode request body: %s", err)
+		}
+	}))
+	defer srv.Close()
+
+	rw, err := newRemoteWriter(srv.URL, "rpi", "pi", time.Second, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Now().UnixNano() / int64(time.Millisecond)
+	if err := rw.write(); err != nil {
+		t.Fatal(err)
+	}
+	end := time.Now().UnixNano() / int64(time.Millisecond)
+
+	for name, value := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if got := header.Get(name); got != value {
+			t.Errorf("Expected header %s to be %q, got %q", name, value, got)
+		}
+	}
+
+	expected := map[string]float64{
+		`test_gauge{instance="pi",job="rpi"}`:                      42.5,
+		`test_total{instance="pi",job="custom"}`:                   3,
+		`test_histogram_bucket{instance="pi",job="rpi",le="1"}`:    1,
+		`test_histogram_bucket{instance="pi",job="rpi",le="2"}`:    2,
+		`test_histogram_bucket{instance="pi",job="rpi",le="+Inf"}`: 3,
+		`test_histogram_sum{instance="pi",job="rpi"}`:              5,
+		`test_histogram_count{instance="pi",job="rpi"}`:            3,
+		`test_summary{instance="pi",job="rpi",quantile="0.5"}`:     1.5,
+		`test_summary_sum{instance="pi",job="rpi"}`:                5,
+		`test_summary_count{instance="pi",job="rpi"}`:              3,
+	}
+	series := decodeWriteRequest(t, body)
+	if len(series) != len(expected) {
+		t.Errorf("Expected %d series, got %d: %v", len(expected), len(series), series)
+	}
+	for name, value := range expected {
+		s, ok := series[name]
+		if !ok {
+			t.Errorf("Missing series %s", name)
+			continue
+		}
+		if s.value != value {
+			t.Errorf("Expected %s to be %g, got %g", name, value, s.value)
+		}
+		if s.timestamp < begin || s.timestamp > end {
+			t.Errorf("Expected timestamp of %s to be between %d and %d, got %d", name, begin, end, s.timestamp)
+		}
+	}
+}
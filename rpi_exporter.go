@@ -15,19 +15,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	promcollectors "github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/log"
 	"github.com/prometheus/common/version"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
@@ -35,21 +43,49 @@ import (
 	"github.com/lukasmalkmus/rpi_exporter/collector"
 )
 
+// The collectors served by the lite handler: temperature, throttling and load.
+var liteCollectors = []string{"cpu", "loadavg", "throttle"}
+
 // A wrapper around http.Handler to handle filtering.
 // Caches already used filter combinations.
 // Create a new handler using newHandler().
 type handler struct {
-	unfilteredHandler http.Handler
-	// There are only three collectors in this program, so that's seven combinations at most.
+	unfilteredHandler  http.Handler
+	unfilteredRegistry *prometheus.Registry
+	// filteredHandlers caches the handlers by their sorted and deduplicated
+	// filters. Only combinations of existing collectors are cached, which
+	// bounds the size of the cache.
+	filteredHandlersMu      sync.Mutex
 	filteredHandlers        map[string]http.Handler
 	exporterMetricsRegistry *prometheus.Registry
 	includeExporterMetrics  bool
+	responseBytes           prometheus.Gauge
+	self                    *selfCollector
+	// async serves the unfiltered metrics from a snapshot taken in the
+	// background, if enabled.
+	async *asyncGatherer
+	// timeoutOffset is subtracted from the scrape timeout sent by Prometheus,
+	// to leave time for the network.
+	timeoutOffset time.Duration
+	// inFlight limits the scrapes in flight, if not nil. Scrapes which timed
+	// out hold their slot until their collectors return, so slow collectors
+	// can't pile up.
+	inFlight chan struct{}
+	// lastScrape is the time of the last successful scrape in nanoseconds
+	// since the unix epoch. Must be accessed atomically.
+	lastScrape int64
 }
 
-func newHandler(includeExporterMetrics bool) *handler {
+func newHandler(includeExporterMetrics, async bool) *handler {
 	h := &handler{
 		filteredHandlers:       make(map[string]http.Handler),
 		includeExporterMetrics: includeExporterMetrics,
+		responseBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "rpi_exporter",
+			Name:      "last_scrape_response_bytes",
+			Help:      "Size of the last metrics response in bytes, as written to the wire.",
+		}),
+		self: newSelfCollector(),
 	}
 
 	// Add default collectors, if they aren't disabled.
@@ -61,6 +97,10 @@ func newHandler(includeExporterMetrics bool) *handler {
 		)
 	}
 
+	if async {
+		h.async = newAsyncGatherer()
+	}
+
 	// Create the unfiltered default handler.
 	unfilteredHandler, err := h.filteredHandler()
 	if err != nil {
@@ -72,20 +112,25 @@ func newHandler(includeExporterMetrics bool) *handler {
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Get the filters from the query.
-	filters := r.URL.Query()["collect[]"]
-	// Sort filters to allow caching of filtered handlers.
-	sort.Strings(filters)
-	log.Debugln("collect query:", filters)
+	// Count the bytes of the response. The size is exported on the next
+	// scrape.
+	cw := &countingResponseWriter{ResponseWriter: w}
+	defer func() {
+		h.responseBytes.Set(float64(cw.n))
+		if cw.status == 0 || cw.status == http.StatusOK {
+			atomic.StoreInt64(&h.lastScrape, time.Now().UnixNano())
+		}
+	}()
+	w = cw
 
-	// Use the unfiltered handler if no filters were given.
-	if len(filters) == 0 {
-		h.unfilteredHandler.ServeHTTP(w, r)
-		return
-	}
+	// Get the filters from the query. Sort and deduplicate them to allow
+	// caching of filtered handlers.
+	filters := uniqueStrings(r.URL.Query()["collect[]"])
+	log.Debugln("collect query:", filters)
 
-	// Create a filtered handler.
-	filteredHandler, err := h.filteredHandler(filters...)
+	// Use the unfiltered handler if no filters were given, otherwise create a
+	// filtered handler.
+	handler, err := h.filteredHandler(filters...)
 	if err != nil {
 		log.Errorln("Couldn't create filtered handler:", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -93,7 +138,60 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filteredHandler.ServeHTTP(w, r)
+	if h.inFlight != nil {
+		select {
+		case h.inFlight <- struct{}{}:
+		default:
+			log.Warnln("Too many scrapes in flight, rejecting scrape")
+			http.Error(w, "Too many scrapes in flight", http.StatusServiceUnavailable)
+			return
+		}
+		inner := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() { <-h.inFlight }()
+			inner.ServeHTTP(w, r)
+		})
+	}
+
+	// Respect the scrape timeout of Prometheus, so it gets an error response
+	// instead of running into the timeout. The deadline is also set on the
+	// context of the request. The collection itself keeps running until its
+	// collectors return.
+	if timeout := h.scrapeTimeout(r); timeout > 0 {
+		handler = http.TimeoutHandler(handler, timeout, "Scrape timed out")
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// scrapeTimeout returns the scrape timeout sent by Prometheus, minus the
+// timeout offset. It is zero if there is none.
+func (h *handler) scrapeTimeout(r *http.Request) time.Duration {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Debugf("Ignoring invalid scrape timeout %q: %s", v, err)
+		return 0
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - h.timeoutOffset
+	if timeout <= 0 {
+		return 0
+	}
+	return timeout
+}
+
+// warmup runs all collectors once, so caches are populated before the first
+// scrape. Errors are only logged.
+func (h *handler) warmup() {
+	begin := time.Now()
+	if _, err := h.unfilteredRegistry.Gather(); err != nil {
+		log.Warnln("Warmup collection failed:", err)
+		return
+	}
+	log.Infof("Warmup collection finished after %fs", time.Since(begin).Seconds())
 }
 
 func (h *handler) filteredHandler(filters ...string) (http.Handler, error) {
@@ -103,6 +201,8 @@ func (h *handler) filteredHandler(filters ...string) (http.Handler, error) {
 	}
 
 	// Check if there is a handler for this combination of filters already.
+	h.filteredHandlersMu.Lock()
+	defer h.filteredHandlersMu.Unlock()
 	filtersStr := strings.Join(filters, ",")
 	handler := h.filteredHandlers[filtersStr]
 	if handler != nil {
@@ -121,13 +221,22 @@ func (h *handler) filteredHandler(filters ...string) (http.Handler, error) {
 	if err := reg.Register(rpiColl); err != nil {
 		return nil, fmt.Errorf("Couldn't register collector: %s", err)
 	}
-	reg.MustRegister(version.NewCollector("rpi_exporter"))
+	reg.MustRegister(version.NewCollector("rpi_exporter"), h.responseBytes, h.self)
+	var gatherer prometheus.Gatherer = reg
+	if len(filters) == 0 {
+		h.unfilteredRegistry = reg
+		// Filtered requests still collect on demand.
+		if h.async != nil {
+			h.async.g = gatherer
+			gatherer = h.async
+		}
+	}
 
 	// Delegate http serving to Prometheus client library, which will call
 	// collector.Collect.
 	if h.includeExporterMetrics {
 		handler = promhttp.HandlerFor(
-			prometheus.Gatherers{h.exporterMetricsRegistry, reg},
+			prometheus.Gatherers{h.exporterMetricsRegistry, gatherer},
 			promhttp.HandlerOpts{
 				ErrorLog:      log.NewErrorLogger(),
 				ErrorHandling: promhttp.HTTPErrorOnError,
@@ -137,7 +246,7 @@ func (h *handler) filteredHandler(filters ...string) (http.Handler, error) {
 			h.exporterMetricsRegistry, handler,
 		)
 	} else {
-		handler = promhttp.HandlerFor(reg,
+		handler = promhttp.HandlerFor(gatherer,
 			promhttp.HandlerOpts{
 				ErrorLog:      log.NewErrorLogger(),
 				ErrorHandling: promhttp.HTTPErrorOnError,
@@ -151,6 +260,151 @@ func (h *handler) filteredHandler(filters ...string) (http.Handler, error) {
 	return handler, nil
 }
 
+// liteHandler returns a handler which only runs the enabled collectors of
+// liteCollectors and omits the exporter metrics, for cheap scrapes.
+func (h *handler) liteHandler() (http.Handler, error) {
+	var filters []string
+	for _, name := range liteCollectors {
+		if collector.Enabled(name) {
+			filters = append(filters, name)
+		}
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("none of the collectors %s is enabled", strings.Join(liteCollectors, ", "))
+	}
+
+	rpiColl, err := collector.New(filters...)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create %s", err)
+	}
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(rpiColl); err != nil {
+		return nil, fmt.Errorf("Couldn't register collector: %s", err)
+	}
+	return promhttp.HandlerFor(reg,
+		promhttp.HandlerOpts{
+			ErrorLog:      log.NewErrorLogger(),
+			ErrorHandling: promhttp.HTTPErrorOnError,
+		}), nil
+}
+
+// gatherer returns the gatherer of the unfiltered handler. It returns the last
+// snapshot, if the metrics are collected in the background.
+func (h *handler) gatherer() prometheus.Gatherer {
+	var g prometheus.Gatherer = h.unfilteredRegistry
+	if h.async != nil {
+		g = h.async
+	}
+	if h.includeExporterMetrics {
+		return prometheus.Gatherers{h.exporterMetricsRegistry, g}
+	}
+	return g
+}
+
+// lastScrapeTime returns the time of the last successful scrape. It is zero if
+// there wasn't any yet.
+func (h *handler) lastScrapeTime() time.Time {
+	nsec := atomic.LoadInt64(&h.lastScrape)
+	if nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec)
+}
+
+// uniqueStrings returns the given strings sorted and without duplicates.
+func uniqueStrings(ss []string) []string {
+	sorted := append([]string(nil), ss...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// countingResponseWriter counts the bytes written to the wrapped
+// http.ResponseWriter and records the status code.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n      int
+	status int
+}
+
+func (w *countingResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.n += n
+	return n, err
+}
+
+// printMetrics gathers the metrics from the given gatherer and writes them to
+// w in the text exposition format.
+func printMetrics(w io.Writer, g prometheus.Gatherer) error {
+	// Gather returns as many metrics as possible, even on error.
+	mfs, gatherErr := g.Gather()
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return gatherErr
+}
+
+// serveMux is a http.ServeMux which validates the paths of the handlers.
+// Paths used by several handlers are an error instead of a panic, a path
+// used repeatedly by the same handler is only registered once.
+type serveMux struct {
+	*http.ServeMux
+	names map[string]string
+	// err is the first invalid path.
+	err error
+}
+
+func newServeMux() *serveMux {
+	return &serveMux{
+		ServeMux: http.NewServeMux(),
+		names:    make(map[string]string),
+	}
+}
+
+// handle registers the handler with the given name for the given path.
+func (m *serveMux) handle(path, name string, h http.Handler) {
+	if m.err != nil {
+		return
+	}
+	if !strings.HasPrefix(path, "/") {
+		m.err = fmt.Errorf("path %q of the %s handler must start with /", path, name)
+		return
+	}
+	if other, ok := m.names[path]; ok {
+		if other != name {
+			m.err = fmt.Errorf("path %s is used by both the %s and the %s handler", path, other, name)
+		}
+		return
+	}
+	m.names[path] = name
+	m.Handle(path, h)
+}
+
+// listen listens on the given TCP address. If the address is in use, it is
+// retried up to the given number of times.
+func listen(addr string, retries int, interval time.Duration) (net.Listener, error) {
+	for i := 0; ; i++ {
+		ln, err := net.Listen("tcp", addr)
+		if err == nil || i >= retries || !errors.Is(err, syscall.EADDRINUSE) {
+			return ln, err
+		}
+		log.Warnf("Couldn't listen on %s, retrying in %s: %s", addr, interval, err)
+		time.Sleep(interval)
+	}
+}
+
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	// A very simple health check.
 	w.Header().Set("Content-Type", "application/json")
@@ -158,13 +412,52 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, `{"alive": true}`)
 }
 
+// VersionHandler serves the build information of the exporter as JSON.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"version":   version.Version,
+		"revision":  version.Revision,
+		"branch":    version.Branch,
+		"goVersion": version.GoVersion,
+	})
+}
+
 func main() {
 	// Command line flags.
 	var (
 		webListenAddress          = kingpin.Flag("web.listen-address", "Address on which to expose metrics and web interface.").Default(":9243").String()
+		webListenRetries          = kingpin.Flag("web.listen-retries", "Number of times to retry listening if the address is in use, e.g. by the previous instance during a restart.").Default("5").Int()
+		webListenRetryInterval    = kingpin.Flag("web.listen-retry-interval", "Interval between attempts to listen.").Default("1s").Duration()
 		webMetricsPath            = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+		webExtraMetricsPaths      = kingpin.Flag("web.extra-telemetry-path", "Additional path under which to expose metrics, e.g. for scrape configs pointing at a non-default path. Can be repeated.").Strings()
 		webHealthPath             = kingpin.Flag("web.healthcheck-path", "Path under which the exporter exposes its status.").Default("/health").String()
+		webTLSCertFile            = kingpin.Flag("web.tls-cert-file", "Path to the TLS certificate. Serves HTTPS if set together with --web.tls-key-file.").Default("").String()
+		webTLSKeyFile             = kingpin.Flag("web.tls-key-file", "Path to the TLS key.").Default("").String()
+		webTLSClientCAFile        = kingpin.Flag("web.tls-client-ca-file", "Path to the CA certificates client certificates must be signed by. If set, requests without a valid client certificate are rejected, except for the health check.").Default("").String()
+		webConfigPath             = kingpin.Flag("web.config-path", "Path under which to expose the effective configuration as JSON. Credentials in urls like --remote-write.url are redacted. Disabled if empty.").Default("").String()
+		webProbePath              = kingpin.Flag("web.probe-path", "Path under which to expose the metrics of the remote rpi_exporter given by the target parameter. Disabled if empty.").Default("").String()
+		webProbeTargets           = kingpin.Flag("web.probe-target", "Remote rpi_exporter which may be probed, as host with optional port. Required by --web.probe-path. Can be repeated.").Strings()
+		webProbeTimeout           = kingpin.Flag("web.probe-timeout", "Timeout of fetching the metrics of a remote rpi_exporter.").Default("10s").Duration()
+		webLitePath               = kingpin.Flag("web.lite-path", "Path under which to expose only the essential metrics (temperature, throttling and load). Disabled if empty.").Default("").String()
+		webTimeoutOffset          = kingpin.Flag("web.timeout-offset", "Offset to subtract from the scrape timeout sent by Prometheus. Scrapes exceeding the remaining timeout are answered with an error.").Default("0.5s").Duration()
+		webMaxRequests            = kingpin.Flag("web.max-requests", "Maximum number of scrapes in flight, including scrapes which timed out but whose collectors are still running. Further scrapes are answered with an error. Unlimited if 0.").Default("10").Int()
+		webJSONPath               = kingpin.Flag("web.json-path", "Path under which to expose the metrics as JSON object, for consumers without a Prometheus parser. Disabled if empty.").Default("/metrics.json").String()
+		webTempPath               = kingpin.Flag("web.temp-path", "Path under which to expose only the SoC temperature, read directly from the thermal zone without running any collector. Disabled if empty.").Default("").String()
 		webDisableExporterMetrics = kingpin.Flag("web.disable-exporter-metrics", "Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).").Bool()
+		collectorWarmup           = kingpin.Flag("collector.warmup", "Run all collectors once in the background after startup, so the first scrape is fast.").Bool()
+		collectorAsync            = kingpin.Flag("collector.async", "Collect the metrics in the background at --collector.interval and serve the last snapshot, instead of collecting on every scrape. Requests filtered by collect[] are still collected on demand.").Bool()
+		collectorTimestamps       = kingpin.Flag("collector.async-timestamps", "Attach the collection time as timestamp to the metrics collected with --collector.async. Prometheus doesn't mark samples with timestamps stale once they disappear, and rejects them if they are older than the head block (about an hour), e.g. after the collection stalled.").Bool()
+		collectorInterval         = kingpin.Flag("collector.interval", "Interval of the background collection with --collector.async.").Default("15s").Duration()
+		collectorPrintOnce        = kingpin.Flag("collector.print-once", "Run all enabled collectors once, print the metrics to stdout and exit.").Bool()
+		pushGateway               = kingpin.Flag("push.gateway", "URL of a Pushgateway to push metrics to. Pushing is disabled if empty.").Default("").String()
+		pushInterval              = kingpin.Flag("push.interval", "Interval at which metrics are pushed to the Pushgateway.").Default("1m").Duration()
+		pushJob                   = kingpin.Flag("push.job", "Job label of metrics pushed to the Pushgateway or remote-write endpoint.").Default("rpi_exporter").String()
+		pushInstance              = kingpin.Flag("push.instance", "Instance label of metrics pushed to the Pushgateway or remote-write endpoint. Defaults to the hostname.").Default("").String()
+		remoteWriteURL            = kingpin.Flag("remote-write.url", "URL of a Prometheus remote-write endpoint to send metrics to. Remote-write is disabled if empty.").Default("").String()
+		remoteWriteInterval       = kingpin.Flag("remote-write.interval", "Interval at which metrics are sent to the remote-write endpoint.").Default("1m").Duration()
+		remoteWriteTimeout        = kingpin.Flag("remote-write.timeout", "Timeout of requests to the remote-write endpoint.").Default("30s").Duration()
+		runtimeMaxProcs           = kingpin.Flag("runtime.gomaxprocs", "Maximum number of CPUs the Go runtime uses (GOMAXPROCS), e.g. 1 to pin the exporter to a single CPU. 0 keeps the Go default.").Default("0").Int()
 	)
 
 	// Setup the command line flags and commands.
@@ -177,20 +470,89 @@ func main() {
 	log.Info("Starting rpi_exporter", version.Info())
 	log.Info("Build context", version.BuildContext())
 
+	// Limit the CPUs used by the runtime, if requested.
+	if *runtimeMaxProcs > 0 {
+		runtime.GOMAXPROCS(*runtimeMaxProcs)
+	}
+	log.Debugln("Using GOMAXPROCS", runtime.GOMAXPROCS(0))
+
+	metricsHandler := newHandler(!*webDisableExporterMetrics, *collectorAsync)
+	metricsHandler.timeoutOffset = *webTimeoutOffset
+	if *webMaxRequests > 0 {
+		metricsHandler.inFlight = make(chan struct{}, *webMaxRequests)
+	}
+
+	// Collect in the background, if requested. This makes a warmup
+	// unnecessary.
+	asyncDone := make(chan struct{})
+	defer close(asyncDone)
+	if *collectorAsync {
+		log.Infof("Collecting metrics every %s", *collectorInterval)
+		metricsHandler.async.timestamps = *collectorTimestamps
+		go metricsHandler.async.run(*collectorInterval, asyncDone)
+	}
+
+	// Print the metrics and exit, if requested.
+	if *collectorPrintOnce {
+		if err := printMetrics(os.Stdout, metricsHandler.gatherer()); err != nil {
+			log.Fatalln("Couldn't print metrics:", err)
+		}
+		return
+	}
+
 	// Setup router and handlers.
-	mux := http.NewServeMux()
-	mux.Handle(*webMetricsPath, newHandler(!*webDisableExporterMetrics))
-	mux.HandleFunc(*webHealthPath, HealthCheckHandler)
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := newServeMux()
+	mux.handle(*webMetricsPath, "metrics", metricsHandler)
+	for _, path := range *webExtraMetricsPaths {
+		mux.handle(path, "metrics", metricsHandler)
+	}
+	mux.handle(*webHealthPath, "health check", http.HandlerFunc(HealthCheckHandler))
+	mux.handle("/version", "version", http.HandlerFunc(VersionHandler))
+	if *webConfigPath != "" {
+		configHandler, err := newConfigHandler(kingpin.CommandLine)
+		if err != nil {
+			log.Fatalln("Couldn't create config handler:", err)
+		}
+		mux.handle(*webConfigPath, "config", configHandler)
+	}
+	if *webProbePath != "" {
+		probeHandler, err := newProbeHandler(*webProbeTimeout, *webProbeTargets)
+		if err != nil {
+			log.Fatalln("Couldn't create probe handler:", err)
+		}
+		mux.handle(*webProbePath, "probe", probeHandler)
+	}
+	if *webLitePath != "" {
+		liteHandler, err := metricsHandler.liteHandler()
+		if err != nil {
+			log.Fatalln("Couldn't create lite metrics handler:", err)
+		}
+		mux.handle(*webLitePath, "lite metrics", liteHandler)
+	}
+	if *webJSONPath != "" {
+		mux.handle(*webJSONPath, "JSON metrics", newJSONHandler(metricsHandler.gatherer()))
+	}
+	if *webTempPath != "" {
+		mux.handle(*webTempPath, "temperature", http.HandlerFunc(TempHandler))
+	}
+	mux.handle("/", "index page", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastScraped := "never"
+		if t := metricsHandler.lastScrapeTime(); !t.IsZero() {
+			lastScraped = time.Since(t).Round(time.Second).String() + " ago"
+		}
 		w.Write([]byte(`<html>
 			<head><title>Raspberry Pi Exporter</title></head>
 			<body>
 			<h1>Raspberry Pi Exporter</h1>
-			<p><a href="` + *webMetricsPath + `">Metrics</a></p>
+			<p><a href="` + *webMetricsPath + `">Metrics</a> (last scraped ` + lastScraped + `)</p>
 			<p><a href="` + *webHealthPath + `">Exporter health</a></p>
+			<p><a href="/version">Version</a></p>
 			</body>
 			</html>`))
-	})
+	}))
+	if mux.err != nil {
+		log.Fatalln("Invalid path:", mux.err)
+	}
 
 	// Setup webserver.
 	srv := &http.Server{
@@ -202,6 +564,27 @@ func main() {
 		ErrorLog:     log.NewErrorLogger(),
 	}
 
+	// Setup TLS and client certificate authentication, if configured. The
+	// health check stays available without a client certificate, so liveness
+	// probes don't need one.
+	useTLS := *webTLSCertFile != "" || *webTLSKeyFile != ""
+	if useTLS && (*webTLSCertFile == "" || *webTLSKeyFile == "") {
+		log.Fatalln("Both --web.tls-cert-file and --web.tls-key-file are required for TLS")
+	}
+	if *webTLSClientCAFile != "" {
+		if !useTLS {
+			log.Fatalln("--web.tls-client-ca-file requires TLS")
+		}
+		srv.Handler = requireClientCert(mux, *webHealthPath)
+	}
+	if useTLS {
+		tlsConfig, err := newTLSConfig(*webTLSClientCAFile)
+		if err != nil {
+			log.Fatalln("Couldn't setup TLS:", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
 	// Listen for termination signals.
 	term := make(chan os.Signal, 1)
 	defer close(term)
@@ -212,12 +595,49 @@ func main() {
 	log.Info("Listening on ", *webListenAddress)
 	webErr := make(chan error)
 	defer close(webErr)
+	ln, err := listen(*webListenAddress, *webListenRetries, *webListenRetryInterval)
+	if err != nil {
+		log.Fatalln("Couldn't listen:", err)
+	}
 	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = srv.ServeTLS(ln, *webTLSCertFile, *webTLSKeyFile)
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != http.ErrServerClosed {
 			webErr <- err
 		}
 	}()
 
+	// Run the collectors once, unless they already run in the background.
+	if *collectorWarmup && !*collectorAsync {
+		go metricsHandler.warmup()
+	}
+
+	// Push metrics to the Pushgateway in the background.
+	pushDone := make(chan struct{})
+	defer close(pushDone)
+	if *pushGateway != "" {
+		pusher, err := newPusher(*pushGateway, *pushJob, *pushInstance, metricsHandler.gatherer())
+		if err != nil {
+			log.Fatalln("Couldn't setup Pushgateway:", err)
+		}
+		log.Infof("Pushing metrics to %s every %s", *pushGateway, *pushInterval)
+		go runPusher(pusher, *pushInterval, pushDone)
+	}
+
+	// Send metrics to the remote-write endpoint in the background.
+	if *remoteWriteURL != "" {
+		rw, err := newRemoteWriter(*remoteWriteURL, *pushJob, *pushInstance, *remoteWriteTimeout, metricsHandler.gatherer())
+		if err != nil {
+			log.Fatalln("Couldn't setup remote-write:", err)
+		}
+		log.Infof("Remote-writing metrics to %s every %s", *remoteWriteURL, *remoteWriteInterval)
+		go runRemoteWriter(rw, *remoteWriteInterval, pushDone)
+	}
+
 	// Wait for a termination signal and shut down gracefully, but wait no
 	// longer than 5 seconds before halting.
 	var (
@@ -0,0 +1,164 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// textfileDir is the directory of the textfile collector.
+var textfileDir string
+
+func TestMain(m *testing.M) {
+	var err error
+	if textfileDir, err = ioutil.TempDir("", "rpi_exporter"); err != nil {
+		panic(err)
+	}
+
+	// Apply the defaults of the collector flags.
+	if _, err := kingpin.CommandLine.Parse([]string{"--collector.textfile.directory=" + textfileDir}); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(textfileDir)
+	os.Exit(code)
+}
+
+// startServer serves the metrics and health check on an ephemeral port of the
+// given host. It returns the base URL and a function stopping the server.
+func startServer(t *testing.T, host string) (string, func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", host+":0")
+	if err != nil {
+		t.Skipf("Couldn't listen on %s: %s", host, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", newHandler(true, false))
+	mux.HandleFunc("/health", HealthCheckHandler)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+
+	return "http://" + ln.Addr().String(), func() { srv.Close() }
+}
+
+func TestServer(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "[::1]"} {
+		t.Run(host, func(t *testing.T) {
+			url, stop := startServer(t, host)
+			defer stop()
+
+			tests := []struct {
+				path     string
+				status   int
+				contains string
+			}{
+				{"/metrics", http.StatusOK, "rpi_scrape_collector_success{"},
+				{"/metrics?collect[]=cpu", http.StatusOK, `rpi_scrape_collector_success{collector="cpu"}`},
+				{"/metrics?collect[]=cpu&collect[]=cpu", http.StatusOK, `rpi_scrape_collector_success{collector="cpu"}`},
+				{"/metrics?collect[]=unknown", http.StatusBadRequest, "missing collector: unknown"},
+				{"/health", http.StatusOK, `{"alive": true}`},
+			}
+			for _, tt := range tests {
+				resp, err := http.Get(url + tt.path)
+				if err != nil {
+					t.Fatalf("GET %s: %s", tt.path, err)
+				}
+				b, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatalf("GET %s: %s", tt.path, err)
+				}
+
+				if resp.StatusCode != tt.status {
+					t.Errorf("GET %s: expected status %d, got %d", tt.path, tt.status, resp.StatusCode)
+				}
+				if !strings.Contains(string(b), tt.contains) {
+					t.Errorf("GET %s: expected body to contain %q, got:\n%s", tt.path, tt.contains, b)
+				}
+			}
+		})
+	}
+}
+
+// scrape serves a scrape of the textfile collector with the given timeout
+// sent by Prometheus, if not empty.
+func scrape(h http.Handler, timeout string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/metrics?collect[]=textfile", nil)
+	if timeout != "" {
+		r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", timeout)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestScrapeTimeout(t *testing.T) {
+	// Opening a FIFO blocks until it is opened for writing, which makes the
+	// textfile collector hang.
+	fifo := filepath.Join(textfileDir, "slow.prom")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fifo)
+
+	h := newHandler(false, false)
+	h.inFlight = make(chan struct{}, 1)
+
+	begin := time.Now()
+	if w := scrape(h, "0.1"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d of a timed out scrape, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Expected the scrape to time out after 0.1s, took %s", elapsed)
+	}
+
+	// The timed out scrape is still running, so it holds the only slot.
+	w := scrape(h, "")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "Too many scrapes in flight") {
+		t.Errorf("Expected too many scrapes in flight, got status %d: %s", w.Code, w.Body)
+	}
+
+	// Let the collector return. The slot is freed once the timed out scrape
+	// finished.
+	f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(fifo)
+	f.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := scrape(h, "")
+		if w.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected status %d once the collector returned, got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"golang.org/x/sys/unix"
+)
+
+// userHZ is the unit of the CPU times in /proc/self/stat.
+const userHZ = 100
+
+// selfCollector exports the CPU time, goroutines, threads and resource limits
+// of the exporter process. Unlike the process collector, it isn't disabled by
+// --web.disable-exporter-metrics.
+type selfCollector struct {
+	cpuSeconds *prometheus.Desc
+	maxFDs     *prometheus.Desc
+	maxProcs   *prometheus.Desc
+	goroutines *prometheus.Desc
+	threads    *prometheus.Desc
+
+	// The limits are read once at startup.
+	fdLimit   float64
+	procLimit float64
+}
+
+func newSelfCollector() *selfCollector {
+	return &selfCollector{
+		cpuSeconds: prometheus.NewDesc(
+			"rpi_exporter_cpu_seconds_total",
+			"Total user and system CPU time of the exporter in seconds.",
+			nil, nil,
+		),
+		maxFDs: prometheus.NewDesc(
+			"rpi_exporter_max_fds",
+			"Maximum number of open file descriptors of the exporter (RLIMIT_NOFILE).",
+			nil, nil,
+		),
+		maxProcs: prometheus.NewDesc(
+			"rpi_exporter_max_procs",
+			"Maximum number of processes of the exporter's user (RLIMIT_NPROC).",
+			nil, nil,
+		),
+		goroutines: prometheus.NewDesc(
+			"rpi_exporter_goroutines",
+			"Number of goroutines of the exporter.",
+			nil, nil,
+		),
+		threads: prometheus.NewDesc(
+			"rpi_exporter_threads",
+			"Number of OS threads created by the exporter.",
+			nil, nil,
+		),
+		fdLimit:   readRlimit(unix.RLIMIT_NOFILE),
+		procLimit: readRlimit(unix.RLIMIT_NPROC),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *selfCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuSeconds
+	ch <- c.maxFDs
+	ch <- c.maxProcs
+	ch <- c.goroutines
+	ch <- c.threads
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *selfCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.maxFDs, prometheus.GaugeValue, c.fdLimit)
+	ch <- prometheus.MustNewConstMetric(c.maxProcs, prometheus.GaugeValue, c.procLimit)
+	ch <- prometheus.MustNewConstMetric(c.goroutines, prometheus.GaugeValue, float64(runtime.NumGoroutine()))
+	ch <- prometheus.MustNewConstMetric(c.threads, prometheus.GaugeValue, float64(pprof.Lookup("threadcreate").Count()))
+
+	seconds, err := readSelfCPUSeconds()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.cpuSeconds, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.cpuSeconds,
+		prometheus.CounterValue, seconds,
+	)
+}
+
+// readSelfCPUSeconds reads the user and system CPU time of the own process
+// from /proc/self/stat.
+func readSelfCPUSeconds() (float64, error) {
+	b, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, err
+	}
+
+	// The fields after the name, which ends at the last ")", start with the
+	// state. utime and stime are the 14th and 15th field.
+	idx := bytes.LastIndexByte(b, ')')
+	if idx == -1 {
+		return 0, fmt.Errorf("invalid stat: %q", b)
+	}
+	fields := bytes.Fields(b[idx+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("invalid stat: %q", b)
+	}
+	utime, err := strconv.ParseFloat(string(fields[11]), 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseFloat(string(fields[12]), 64)
+	if err != nil {
+		return 0, err
+	}
+	return (utime + stime) / userHZ, nil
+}
+
+// readRlimit returns the soft limit of the given resource. It is +Inf if the
+// resource is unlimited, NaN if it can't be read.
+func readRlimit(resource int) float64 {
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(resource, &rlimit); err != nil {
+		log.Warnf("Couldn't read resource limit: %s", err)
+		return math.NaN()
+	}
+	if rlimit.Cur == unix.RLIM_INFINITY {
+		return math.Inf(1)
+	}
+	return float64(rlimit.Cur)
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Lukas Malkmus
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/log"
+
+	"github.com/lukasmalkmus/rpi_exporter/collector"
+)
+
+// TempHandler serves only the SoC temperature, read directly from the thermal
+// zone. It bypasses the collectors and vcgencmd for the cheapest scrape
+// possible.
+func TempHandler(w http.ResponseWriter, r *http.Request) {
+	temp, err := collector.SoCTemperature()
+	if err != nil {
+		log.Errorln("Couldn't read SoC temperature:", err)
+		http.Error(w, fmt.Sprintf("Couldn't read SoC temperature: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", string(expfmt.FmtText))
+	fmt.Fprintf(w, "# HELP rpi_soc_temperature_celsius SoC temperature in degrees celsius (°C).\n"+
+		"# TYPE rpi_soc_temperature_celsius gauge\n"+
+		"rpi_soc_temperature_celsius %s\n", strconv.FormatFloat(temp, 'g', -1, 64))
+}